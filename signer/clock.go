@@ -0,0 +1,171 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock - источник времени для задержек подписи, очереди md5 и окон
+type Clock interface {
+	Now() time.Time
+	Sleep(d time.Duration)
+	After(d time.Duration) <-chan time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// SystemClock - обычное время
+var SystemClock Clock = systemClock{}
+
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return SystemClock
+	}
+	return c
+}
+
+type sleeper struct {
+	until time.Time
+	wake  chan time.Time
+}
+
+// VirtualClock - время для тестов, которое идёт только когда его двигают:
+// вручную через Advance или само через AutoAdvance, когда уснуло сколько нужно.
+// Секундные задержки подписи так проходят за микросекунды.
+type VirtualClock struct {
+	mu       sync.Mutex
+	now      time.Time
+	sleepers []*sleeper
+	// changed закрывается, когда кто-то засыпает - на нём ждут BlockUntil и AutoAdvance
+	changed chan struct{}
+}
+
+func NewVirtualClock(start time.Time) *VirtualClock {
+	return &VirtualClock{now: start}
+}
+
+func (c *VirtualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *VirtualClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	wake := make(chan time.Time, 1)
+	if d <= 0 {
+		wake <- c.now
+		return wake
+	}
+	c.sleepers = append(c.sleepers, &sleeper{until: c.now.Add(d), wake: wake})
+	if c.changed != nil {
+		close(c.changed)
+		c.changed = nil
+	}
+	return wake
+}
+
+func (c *VirtualClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Sleepers - сколько сейчас ждут
+func (c *VirtualClock) Sleepers() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.sleepers)
+}
+
+// BlockUntil ждёт, пока будут ждать хотя бы n. Брошенные After тоже считаются,
+// пока их срок не пройдёт.
+func (c *VirtualClock) BlockUntil(n int) {
+	c.waitSleepers(n, nil)
+}
+
+// waitSleepers - BlockUntil, который можно прервать через stop
+func (c *VirtualClock) waitSleepers(n int, stop <-chan struct{}) bool {
+	for {
+		c.mu.Lock()
+		if len(c.sleepers) >= n {
+			c.mu.Unlock()
+			return true
+		}
+		if c.changed == nil {
+			c.changed = make(chan struct{})
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-stop:
+			return false
+		}
+	}
+}
+
+// Advance двигает время на d и будит всех, чей срок подошёл
+func (c *VirtualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advanceTo(c.now.Add(d))
+}
+
+func (c *VirtualClock) advanceTo(t time.Time) {
+	if t.After(c.now) {
+		c.now = t
+	}
+	sort.SliceStable(c.sleepers, func(i, j int) bool {
+		return c.sleepers[i].until.Before(c.sleepers[j].until)
+	})
+	i := 0
+	for ; i < len(c.sleepers) && !c.sleepers[i].until.After(c.now); i++ {
+		c.sleepers[i].wake <- c.now
+	}
+	c.sleepers = c.sleepers[i:]
+}
+
+// advanceToNext переводит время на ближайший срок, если кто-то ждёт
+func (c *VirtualClock) advanceToNext() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.sleepers) == 0 {
+		return false
+	}
+	next := c.sleepers[0].until
+	for _, s := range c.sleepers {
+		if s.until.Before(next) {
+			next = s.until
+		}
+	}
+	c.advanceTo(next)
+	return true
+}
+
+// AutoAdvance в фоне переводит время к ближайшему сроку, как только ждут хотя бы sleepers.
+// Подходит, когда тест знает, сколько горутин спит, если всем больше нечего делать,
+// и время между пробуждением и следующим сном никто другой не смотрит. Иначе время
+// надо двигать самому через BlockUntil и Advance. Возвращает функцию остановки.
+func (c *VirtualClock) AutoAdvance(sleepers int) (stop func()) {
+	if sleepers < 1 {
+		sleepers = 1
+	}
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for c.waitSleepers(sleepers, done) {
+			c.advanceToNext()
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var virtualStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestVirtualClockAdvance(t *testing.T) {
+	c := NewVirtualClock(virtualStart)
+	woken := make(chan time.Duration, 3)
+	for _, d := range []time.Duration{3 * time.Second, time.Second, 2 * time.Second} {
+		go func(d time.Duration) {
+			c.Sleep(d)
+			woken <- d
+		}(d)
+	}
+	c.BlockUntil(3)
+
+	c.Advance(1500 * time.Millisecond)
+	if d := <-woken; d != time.Second {
+		t.Errorf("bad sleeper woken: %v", d)
+	}
+	if n := c.Sleepers(); n != 2 {
+		t.Errorf("bad sleepers count: %d", n)
+	}
+
+	c.Advance(10 * time.Second)
+	<-woken
+	<-woken
+	if end := c.Now().Sub(virtualStart); end != 11500*time.Millisecond {
+		t.Errorf("bad virtual time: %v", end)
+	}
+}
+
+func TestSignerVirtualClock(t *testing.T) {
+	testExpected := "1173136728138862632818075107442090076184424490584241521304_1696913515191343735512658979631549563179965036907783101867_27225454331033649287118297354036464389062965355426795162684_29568666068035183841425683795340791879727309630931025356555_3994492081516972096677631278379039212655368881548151736_4958044192186797981418233587017209679042592862002427381542_4958044192186797981418233587017209679042592862002427381542"
+
+	clock := NewVirtualClock(virtualStart)
+	s := NewSignerWith(SignerConfig{Md5Delay: 10 * time.Millisecond, Crc32Delay: time.Second, Clock: clock})
+	go func() {
+		// SingleHash: спят 7 crc32 от данных и один md5, каждый md5 через 10мс
+		// пускает следующий и засыпает в crc32 от своего результата
+		clock.BlockUntil(8)
+		for i := 1; i < 7; i++ {
+			clock.Advance(10 * time.Millisecond)
+			clock.BlockUntil(8 + i)
+		}
+		clock.Advance(10 * time.Millisecond)
+		clock.BlockUntil(14)
+		// к 1.07с готовы все SingleHash, дальше 7*6 crc32 в MultiHash
+		clock.Advance(time.Second)
+		clock.BlockUntil(42)
+		clock.Advance(time.Second)
+	}()
+
+	realStart := time.Now()
+	res, err := s.Sign(context.Background(), "0", "1", "1", "2", "3", "5", "8")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", res, testExpected)
+	}
+
+	// SingleHash: md5 по очереди 7*10мс + crc32 1с, MultiHash - ещё 1с
+	if end := clock.Now().Sub(virtualStart); end != 2070*time.Millisecond {
+		t.Errorf("bad virtual execution time\nGot: %s\nExpected: 2.07s", end)
+	}
+	if real := time.Since(realStart); real > time.Second {
+		t.Errorf("virtual clock must not wait for real\nGot: %s", real)
+	}
+	if stats := s.Md5Stats(); stats.Acquired != 7 {
+		t.Errorf("bad md5 stats: %+v", stats)
+	}
+}
+
+func TestByIliaVirtualClock(t *testing.T) {
+	clock := NewVirtualClock(virtualStart)
+	// спит только второе звено
+	stop := clock.AutoAdvance(1)
+	defer stop()
+
+	var recieved uint32
+	err := ExecutePipelineContext(context.Background(),
+		func(ctx context.Context, in, out chan interface{}) {
+			out <- uint32(1)
+			out <- uint32(3)
+			out <- uint32(4)
+		},
+		func(ctx context.Context, in, out chan interface{}) {
+			for val := range in {
+				out <- val.(uint32) * 3
+				clock.Sleep(time.Millisecond * 100)
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) {
+			for val := range in {
+				atomic.AddUint32(&recieved, val.(uint32))
+			}
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if end := clock.Now().Sub(virtualStart); end != 300*time.Millisecond {
+		t.Errorf("bad virtual execution time\nGot: %s\nExpected: 300ms", end)
+	}
+	if recieved != (1+3+4)*3 {
+		t.Errorf("f3 have not collected inputs, recieved = %d", recieved)
+	}
+}
@@ -1,14 +1,5 @@
 package main
 
-import (
-	"crypto/md5"
-	"fmt"
-	"hash/crc32"
-	"strconv"
-	"sync/atomic"
-	"time"
-)
-
 type job func(in, out chan interface{})
 
 const (
@@ -20,41 +11,20 @@ var (
 	DataSignerSalt            = ""
 )
 
+// функции ниже - тонкие обёртки над defaultSigner, их можно подменять целиком
+
 var OverheatLock = func() {
-	for {
-		if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 0, 1); !swapped {
-			fmt.Println("OverheatLock happend")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
-	}
+	overheatGuard.Lock()
 }
 
 var OverheatUnlock = func() {
-	for {
-		if swapped := atomic.CompareAndSwapUint32(&dataSignerOverheat, 1, 0); !swapped {
-			fmt.Println("OverheatUnlock happend")
-			time.Sleep(time.Second)
-		} else {
-			break
-		}
-	}
+	overheatGuard.Unlock()
 }
 
 var DataSignerMd5 = func(data string) string {
-	OverheatLock()
-	defer OverheatUnlock()
-	data += DataSignerSalt
-	dataHash := fmt.Sprintf("%x", md5.Sum([]byte(data)))
-	time.Sleep(10 * time.Millisecond)
-	return dataHash
+	return defaultSigner.DataSignerMd5(data)
 }
 
 var DataSignerCrc32 = func(data string) string {
-	data += DataSignerSalt
-	crcH := crc32.ChecksumIEEE([]byte(data))
-	dataHash := strconv.FormatUint(uint64(crcH), 10)
-	time.Sleep(time.Second)
-	return dataHash
+	return defaultSigner.DataSignerCrc32(data)
 }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+)
+
+// ExternalSort - настройки CombineTo для входа, который не помещается в память
+type ExternalSort struct {
+	// MemoryLimit - сколько байт строк держать в памяти, прежде чем сбросить
+	// отсортированный кусок во временный файл. 0 - 64 МБ
+	MemoryLimit int
+	// TempDir - где создавать временные файлы, "" - os.TempDir()
+	TempDir string
+}
+
+const defaultMemoryLimit = 64 << 20
+
+// на каждую строку в памяти сверх её байт уходит заголовок строки в срезе
+const stringOverhead = 16
+
+// CombineTo делает то же, что CombineResults, но не держит весь вход в памяти:
+// отсортированные куски уходят во временные файлы, потом они сливаются
+// и результат через sep пишется в w по мере слияния. Возвращает, сколько байт записано.
+// Временные файлы удаляются в любом случае.
+func CombineTo(ctx context.Context, in <-chan string, w io.Writer, sep string, cfg ExternalSort) (int64, error) {
+	limit := cfg.MemoryLimit
+	if limit <= 0 {
+		limit = defaultMemoryLimit
+	}
+
+	var (
+		runs []*os.File
+		buf  []string
+		size int
+	)
+	defer func() {
+		for _, f := range runs {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	for {
+		var data string
+		var ok bool
+		select {
+		case data, ok = <-in:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+		if !ok {
+			break
+		}
+		buf = append(buf, data)
+		size += len(data) + stringOverhead
+		if size >= limit {
+			f, err := spillRun(cfg.TempDir, buf)
+			if f != nil {
+				runs = append(runs, f)
+			}
+			if err != nil {
+				return 0, err
+			}
+			buf, size = buf[:0], 0
+		}
+	}
+
+	sort.Strings(buf)
+	sources := []runSource{&sliceRun{vals: buf}}
+	for _, f := range runs {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		sources = append(sources, &fileRun{r: bufio.NewReader(f)})
+	}
+	return mergeRuns(ctx, sources, w, sep)
+}
+
+// spillRun сортирует кусок и пишет его во временный файл: длина uvarint, потом байты строки
+func spillRun(dir string, vals []string) (*os.File, error) {
+	sort.Strings(vals)
+	f, err := os.CreateTemp(dir, "signer-run-*")
+	if err != nil {
+		return nil, err
+	}
+	bw := bufio.NewWriter(f)
+	var lenBuf [binary.MaxVarintLen64]byte
+	for _, val := range vals {
+		n := binary.PutUvarint(lenBuf[:], uint64(len(val)))
+		if _, err := bw.Write(lenBuf[:n]); err != nil {
+			return f, err
+		}
+		if _, err := bw.WriteString(val); err != nil {
+			return f, err
+		}
+	}
+	return f, bw.Flush()
+}
+
+// runSource - отсортированный кусок, next возвращает io.EOF в конце
+type runSource interface {
+	next() (string, error)
+}
+
+type sliceRun struct {
+	vals []string
+}
+
+func (r *sliceRun) next() (string, error) {
+	if len(r.vals) == 0 {
+		return "", io.EOF
+	}
+	val := r.vals[0]
+	r.vals = r.vals[1:]
+	return val, nil
+}
+
+type fileRun struct {
+	r *bufio.Reader
+}
+
+func (r *fileRun) next() (string, error) {
+	n, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return string(buf), nil
+}
+
+type runHead struct {
+	val string
+	src runSource
+}
+
+type runHeap []runHead
+
+func (h runHeap) Len() int            { return len(h) }
+func (h runHeap) Less(i, j int) bool  { return h[i].val < h[j].val }
+func (h runHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *runHeap) Push(x interface{}) { *h = append(*h, x.(runHead)) }
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// mergeRuns сливает куски через кучу и пишет результат в w
+func mergeRuns(ctx context.Context, sources []runSource, w io.Writer, sep string) (int64, error) {
+	h := make(runHeap, 0, len(sources))
+	for _, src := range sources {
+		val, err := src.next()
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		h = append(h, runHead{val: val, src: src})
+	}
+	heap.Init(&h)
+
+	bw := bufio.NewWriter(w)
+	var written int64
+	write := func(s string) error {
+		n, err := bw.WriteString(s)
+		written += int64(n)
+		return err
+	}
+	for first := true; h.Len() > 0; first = false {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		if !first {
+			if err := write(sep); err != nil {
+				return written, err
+			}
+		}
+		if err := write(h[0].val); err != nil {
+			return written, err
+		}
+		val, err := h[0].src.next()
+		switch {
+		case err == io.EOF:
+			heap.Pop(&h)
+		case err != nil:
+			return written, err
+		default:
+			h[0].val = val
+			heap.Fix(&h, 0)
+		}
+	}
+	return written, bw.Flush()
+}
+
+// CombineToStage - звено, которое пишет подпись в w через CombineTo
+// и отдаёт, сколько байт записано
+func (s *Signer) CombineToStage(w io.Writer, cfg ExternalSort) Stage[string, int64] {
+	return func(ctx context.Context, in <-chan string, out chan<- int64) error {
+		n, err := CombineTo(ctx, in, w, s.CombineSep, cfg)
+		if err != nil {
+			return err
+		}
+		if !emit(ctx, out, n) {
+			return ctx.Err()
+		}
+		return nil
+	}
+}
+
+// SignTo считает подпись значений из канала и пишет её в w, не собирая вход в памяти
+func (s *Signer) SignTo(ctx context.Context, w io.Writer, cfg ExternalSort, data <-chan string) error {
+	chain := Then(Then(Then(Source(func(ctx context.Context, out chan<- string) error {
+		for d := range data {
+			if !emit(ctx, out, d) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}), s.SingleHashStage()), s.MultiHashStage()), s.CombineToStage(w, cfg))
+	return chain.With(s.Pipeline).Run(ctx, func(int64) error { return nil })
+}
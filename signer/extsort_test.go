@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCombineToSpills(t *testing.T) {
+	dir := t.TempDir()
+	vals := make([]string, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		// разной длины и с переводами строк, чтобы проверить формат кусков
+		vals = append(vals, fmt.Sprintf("%d\n%s", (i*7919)%1000, strings.Repeat("x", i%5)))
+	}
+	in := make(chan string, len(vals))
+	for _, v := range vals {
+		in <- v
+	}
+	close(in)
+
+	var out strings.Builder
+	n, err := CombineTo(context.Background(), in, &out, "_", ExternalSort{MemoryLimit: 1000, TempDir: dir})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := combine("_", vals)
+	if out.String() != expected {
+		t.Errorf("results not match\nGot: %q\nExpected: %q", out.String()[:100], expected[:100])
+	}
+	if n != int64(len(expected)) {
+		t.Errorf("bad written count: %d, expected %d", n, len(expected))
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("temp files left: %v", files)
+	}
+}
+
+type failWriter struct{}
+
+var errDiskFull = errors.New("disk full")
+
+func (failWriter) Write(p []byte) (int, error) {
+	return 0, errDiskFull
+}
+
+func TestCombineToWriterError(t *testing.T) {
+	dir := t.TempDir()
+	in := make(chan string, 100)
+	for i := 0; i < 100; i++ {
+		in <- fmt.Sprint(i)
+	}
+	close(in)
+	if _, err := CombineTo(context.Background(), in, failWriter{}, "_", ExternalSort{MemoryLimit: 100, TempDir: dir}); !errors.Is(err, errDiskFull) {
+		t.Errorf("expected writer error, got %v", err)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Errorf("temp files left: %v", files)
+	}
+}
+
+func TestSignTo(t *testing.T) {
+	s := newFastSigner("")
+	data := []string{"0", "1", "1", "2", "3", "5", "8"}
+	expected, err := s.Sign(context.Background(), data...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, d := range data {
+			in <- d
+		}
+	}()
+	var out strings.Builder
+	if err := s.SignTo(context.Background(), &out, ExternalSort{MemoryLimit: 200, TempDir: t.TempDir()}, in); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", out.String(), expected)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Graph - конвейер в виде графа: у звена может быть несколько входов и несколько
+// читателей. Выход звена с несколькими читателями рассылается каждому целиком,
+// несколько входов сливаются в один. Звенья те же, что у ExecutePipelineErr.
+type Graph struct {
+	cfg   PipelineConfig
+	nodes []*Node
+	err   error
+}
+
+// Node - звено графа, его передают как вход следующим звеньям
+type Node struct {
+	graph   *Graph
+	index   int
+	name    string
+	run     jobErr
+	inputs  []*Node
+	ordered bool
+	readers int
+}
+
+func NewGraph() *Graph {
+	return &Graph{}
+}
+
+// With задаёт настройки запуска, номера звеньев в них - в порядке добавления, с нуля
+func (g *Graph) With(cfg PipelineConfig) *Graph {
+	g.cfg = cfg
+	return g
+}
+
+// Add добавляет звено. Без inputs это источник, с несколькими inputs значения
+// от них приходят вперемешку, по мере готовности.
+func (g *Graph) Add(name string, fn jobErr, inputs ...*Node) *Node {
+	return g.add(name, fn, false, inputs)
+}
+
+// Merge добавляет звено, которое берёт по одному значению от каждого входа по очереди:
+// первое от inputs[0], первое от inputs[1], ..., потом вторые. Так значения из веток,
+// которые сохраняют порядок, приходят к звену рядом. Закончившийся вход пропускается.
+// fn == nil - звено просто отдаёт слитый поток дальше.
+func (g *Graph) Merge(name string, fn jobErr, inputs ...*Node) *Node {
+	return g.add(name, fn, true, inputs)
+}
+
+func (g *Graph) add(name string, fn jobErr, ordered bool, inputs []*Node) *Node {
+	if fn == nil {
+		fn = forward
+	}
+	n := &Node{graph: g, index: len(g.nodes), name: name, run: fn, inputs: inputs, ordered: ordered}
+	for _, in := range inputs {
+		if in == nil || in.graph != g {
+			if g.err == nil {
+				g.err = fmt.Errorf("graph: input of %q is not a node of this graph", name)
+			}
+			continue
+		}
+		in.readers++
+	}
+	g.nodes = append(g.nodes, n)
+	return n
+}
+
+func forward(ctx context.Context, in, out chan interface{}) error {
+	for val := range in {
+		if !send(ctx, out, val) {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Run запускает все звенья и ждёт их. Ошибки - как у ExecutePipelineErr,
+// выходы звеньев без читателей вычитываются и пропадают.
+func (g *Graph) Run(ctx context.Context) error {
+	if g.err != nil {
+		return g.err
+	}
+	if len(g.nodes) == 0 {
+		return errors.New("graph: no nodes")
+	}
+	rg := newRunGroup(ctx, g.cfg)
+
+	// branches[i] - каналы, по которым выход звена i расходится читателям, по одному на читателя
+	branches := make([][]chan interface{}, len(g.nodes))
+	outs := make([]chan interface{}, len(g.nodes))
+	for i, n := range g.nodes {
+		out, next := link[interface{}](rg, i, n.name, g.soleReader(n))
+		outs[i] = out
+		switch n.readers {
+		case 0:
+			go drain(next)
+		case 1:
+			branches[i] = []chan interface{}{next}
+		default:
+			capacity := g.cfg.link(i).capacity()
+			bs := make([]chan interface{}, n.readers)
+			for r := range bs {
+				bs[r] = make(chan interface{}, capacity)
+			}
+			branches[i] = bs
+			rg.goHelper(func() { broadcast(rg.ctx, next, bs) })
+		}
+	}
+
+	for i, n := range g.nodes {
+		inputs := make([]chan interface{}, 0, len(n.inputs))
+		for _, src := range n.inputs {
+			// каждый читатель забирает свою ветку
+			inputs = append(inputs, branches[src.index][0])
+			branches[src.index] = branches[src.index][1:]
+		}
+		in := mergeInputs(rg, inputs, n.ordered)
+
+		out, stageRun := outs[i], n.run
+		run := isolate(rg, i, n.name, in, func(ctx context.Context, in chan interface{}) error {
+			return stageRun(ctx, in, out)
+		})
+		rg.goStage(i, n.name, func() error {
+			defer close(out)
+			return run()
+		})
+	}
+	return rg.wait()
+}
+
+// soleReader - номер звена, которое читает выход n, если оно одно и других входов у него нет.
+// Иначе -1: простои читателя тогда не считаются
+func (g *Graph) soleReader(n *Node) int {
+	if n.readers != 1 {
+		return -1
+	}
+	for _, m := range g.nodes {
+		for _, in := range m.inputs {
+			if in == n && len(m.inputs) == 1 {
+				return m.index
+			}
+		}
+	}
+	return -1
+}
+
+// broadcast отдаёт каждое значение во все ветки. Медленный читатель
+// задерживает остальных, когда его ветка заполнится.
+func broadcast(ctx context.Context, in chan interface{}, branches []chan interface{}) {
+	defer func() {
+		for _, b := range branches {
+			close(b)
+		}
+	}()
+	for val := range in {
+		for _, b := range branches {
+			if !send(ctx, b, val) {
+				drain(in)
+				return
+			}
+		}
+	}
+}
+
+// mergeInputs сливает входы звена в один канал
+func mergeInputs(g *runGroup, inputs []chan interface{}, ordered bool) chan interface{} {
+	switch len(inputs) {
+	case 0:
+		in := make(chan interface{})
+		close(in)
+		return in
+	case 1:
+		return inputs[0]
+	}
+
+	merged := make(chan interface{})
+	stop := func() {
+		for _, in := range inputs {
+			go drain(in)
+		}
+	}
+	if ordered {
+		g.goHelper(func() {
+			defer close(merged)
+			for len(inputs) > 0 {
+				for i := 0; i < len(inputs); {
+					var val interface{}
+					var ok bool
+					select {
+					case val, ok = <-inputs[i]:
+					case <-g.ctx.Done():
+						stop()
+						return
+					}
+					if !ok {
+						inputs = append(inputs[:i], inputs[i+1:]...)
+						continue
+					}
+					if !send(g.ctx, merged, val) {
+						stop()
+						return
+					}
+					i++
+				}
+			}
+		})
+		return merged
+	}
+
+	var wg sync.WaitGroup
+	for _, in := range inputs {
+		in := in
+		wg.Add(1)
+		g.goHelper(func() {
+			defer wg.Done()
+			for val := range in {
+				if !send(g.ctx, merged, val) {
+					drain(in)
+					return
+				}
+			}
+		})
+	}
+	g.goHelper(func() {
+		wg.Wait()
+		close(merged)
+	})
+	return merged
+}
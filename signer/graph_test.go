@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+)
+
+// mapJob - звено, которое применяет fn к каждому значению по порядку
+func mapJob(fn func(data string) string) jobErr {
+	return func(ctx context.Context, in, out chan interface{}) error {
+		for val := range in {
+			if !send(ctx, out, fn(val.(string))) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
+
+func TestGraphSingleHash(t *testing.T) {
+	s := newFastSigner("")
+	data := []string{"0", "1", "1", "2", "3", "5", "8"}
+
+	var (
+		mu      sync.Mutex
+		audit   []string
+		results []string
+	)
+	g := NewGraph()
+	src := g.Add("source", func(ctx context.Context, in, out chan interface{}) error {
+		for _, d := range data {
+			out <- d
+		}
+		return nil
+	})
+	crc := g.Add("crc32", mapJob(s.DataSignerCrc32), src)
+	md5 := g.Add("md5", mapJob(s.DataSignerMd5), src)
+	md5crc := g.Add("md5 crc32", mapJob(s.DataSignerCrc32), md5)
+	g.Add("audit", func(ctx context.Context, in, out chan interface{}) error {
+		for val := range in {
+			mu.Lock()
+			audit = append(audit, val.(string))
+			mu.Unlock()
+		}
+		return nil
+	}, md5)
+	join := g.Merge("join", func(ctx context.Context, in, out chan interface{}) error {
+		for left := range in {
+			out <- left.(string) + "~" + (<-in).(string)
+		}
+		return nil
+	}, crc, md5crc)
+	g.Add("sink", func(ctx context.Context, in, out chan interface{}) error {
+		for val := range in {
+			results = append(results, val.(string))
+		}
+		return nil
+	}, join)
+
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := make([]string, 0, len(data))
+	md5s := make([]string, 0, len(data))
+	for _, d := range data {
+		expected = append(expected, s.Crc32(d)+"~"+s.Crc32(s.Md5(d)))
+		md5s = append(md5s, s.Md5(d))
+	}
+	if !reflect.DeepEqual(results, expected) {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", results, expected)
+	}
+	if !reflect.DeepEqual(audit, md5s) {
+		t.Errorf("audit must see every md5\nGot: %v\nExpected: %v", audit, md5s)
+	}
+}
+
+func TestGraphUnorderedMerge(t *testing.T) {
+	source := func(vals ...int) jobErr {
+		return func(ctx context.Context, in, out chan interface{}) error {
+			for _, v := range vals {
+				out <- v
+			}
+			return nil
+		}
+	}
+	var res []int
+	m := NewMetrics()
+	g := NewGraph().With(PipelineConfig{Metrics: m})
+	a := g.Add("a", source(1, 2, 3))
+	b := g.Add("b", source(10, 20))
+	g.Add("sink", func(ctx context.Context, in, out chan interface{}) error {
+		for val := range in {
+			res = append(res, val.(int))
+		}
+		return nil
+	}, a, b)
+	if err := g.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	sort.Ints(res)
+	if !reflect.DeepEqual(res, []int{1, 2, 3, 10, 20}) {
+		t.Errorf("bad merge: %v", res)
+	}
+	stages := m.Stages()
+	if len(stages) != 3 || stages[0].ItemsOut != 3 || stages[1].ItemsOut != 2 || stages[2].Name != "sink" {
+		t.Errorf("bad metrics: %+v", stages)
+	}
+}
+
+func TestGraphError(t *testing.T) {
+	errBroken := errors.New("broken branch")
+	g := NewGraph()
+	src := g.Add("source", func(ctx context.Context, in, out chan interface{}) error {
+		// обычное звено без ctx, отправки после ошибки должны вычитываться
+		for i := 0; i < 1000; i++ {
+			out <- i
+		}
+		return nil
+	})
+	g.Add("slow", func(ctx context.Context, in, out chan interface{}) error {
+		for range in {
+		}
+		return nil
+	}, src)
+	g.Add("broken", func(ctx context.Context, in, out chan interface{}) error {
+		<-in
+		return errBroken
+	}, src)
+
+	err := g.Run(context.Background())
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Index != 2 || stageErr.Name != "broken" || !errors.Is(err, errBroken) {
+		t.Fatalf("expected error of broken branch, got %v", err)
+	}
+}
+
+func TestGraphForeignNode(t *testing.T) {
+	other := NewGraph()
+	src := other.Add("source", forward)
+	g := NewGraph()
+	g.Add("sink", forward, src)
+	if err := g.Run(context.Background()); err == nil {
+		t.Errorf("expected error for node of another graph")
+	}
+}
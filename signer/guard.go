@@ -0,0 +1,113 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// границы корзин гистограммы ожидания, последняя корзина - всё, что дольше
+var guardWaitBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Md5Guard пускает к md5 строго по одному и в порядке очереди. Ожидающие не крутятся
+// в цикле, а спят на своём канале, освобождающий сразу передаёт владение следующему.
+type Md5Guard struct {
+	// Clock - для замера ожидания, по умолчанию обычное время
+	Clock Clock
+
+	mu     sync.Mutex
+	locked bool
+	queue  []chan struct{}
+
+	acquired  uint64
+	overheats uint64
+	waitSum   time.Duration
+	waitCount []uint64
+}
+
+// GuardStats - снимок статистики Md5Guard
+type GuardStats struct {
+	// сколько сейчас ждут в очереди
+	QueueLen int
+	// сколько раз guard был захвачен
+	Acquired uint64
+	// сколько раз md5 был занят в момент вызова - раньше это означало перегрев
+	Overheats uint64
+	// WaitBuckets[i] - сколько ожиданий уложилось в (Buckets[i-1], Buckets[i]],
+	// последний элемент - ожидания дольше последней границы
+	Buckets     []time.Duration
+	WaitBuckets []uint64
+	WaitSum     time.Duration
+}
+
+func (g *Md5Guard) observe(wait time.Duration) {
+	if g.waitCount == nil {
+		g.waitCount = make([]uint64, len(guardWaitBuckets)+1)
+	}
+	g.acquired++
+	g.waitSum += wait
+	i := 0
+	for i < len(guardWaitBuckets) && wait > guardWaitBuckets[i] {
+		i++
+	}
+	g.waitCount[i]++
+}
+
+func (g *Md5Guard) Lock() {
+	g.mu.Lock()
+	if !g.locked {
+		g.locked = true
+		g.observe(0)
+		g.mu.Unlock()
+		return
+	}
+	wake := make(chan struct{})
+	g.queue = append(g.queue, wake)
+	g.overheats++
+	g.mu.Unlock()
+
+	clock := clockOrSystem(g.Clock)
+	start := clock.Now()
+	<-wake
+	wait := clock.Now().Sub(start)
+
+	g.mu.Lock()
+	g.observe(wait)
+	g.mu.Unlock()
+}
+
+func (g *Md5Guard) Unlock() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.locked {
+		panic("Md5Guard: unlock of unlocked guard")
+	}
+	if len(g.queue) == 0 {
+		g.locked = false
+		return
+	}
+	// guard остаётся занятым - владение переходит первому в очереди
+	wake := g.queue[0]
+	g.queue = g.queue[1:]
+	close(wake)
+}
+
+func (g *Md5Guard) Stats() GuardStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	stats := GuardStats{
+		QueueLen:    len(g.queue),
+		Acquired:    g.acquired,
+		Overheats:   g.overheats,
+		Buckets:     append([]time.Duration{}, guardWaitBuckets...),
+		WaitBuckets: make([]uint64, len(guardWaitBuckets)+1),
+		WaitSum:     g.waitSum,
+	}
+	copy(stats.WaitBuckets, g.waitCount)
+	return stats
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMd5GuardFIFO(t *testing.T) {
+	g := &Md5Guard{}
+	g.Lock()
+
+	const waiters = 5
+	var (
+		mu    sync.Mutex
+		order []int
+		wg    sync.WaitGroup
+	)
+	for i := 0; i < waiters; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			g.Lock()
+			mu.Lock()
+			order = append(order, i)
+			mu.Unlock()
+			g.Unlock()
+		}(i)
+		// следующий встаёт в очередь только после предыдущего
+		for g.Stats().QueueLen != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	g.Unlock()
+	wg.Wait()
+
+	for i, got := range order {
+		if got != i {
+			t.Fatalf("waiters must be served in FIFO order, got %v", order)
+		}
+	}
+
+	stats := g.Stats()
+	if stats.QueueLen != 0 || stats.Acquired != waiters+1 || stats.Overheats != waiters {
+		t.Errorf("bad stats: %+v", stats)
+	}
+	var total uint64
+	for _, c := range stats.WaitBuckets {
+		total += c
+	}
+	if total != waiters+1 {
+		t.Errorf("every acquire must be in histogram: %+v", stats)
+	}
+	// первый ждал не меньше 20мс - попадает в корзину (10ms, 100ms]
+	if stats.WaitBuckets[2] == 0 || stats.WaitSum < 20*time.Millisecond {
+		t.Errorf("long waits not recorded: %+v", stats)
+	}
+}
+
+func TestMd5GuardUnlockUnlocked(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic")
+		}
+	}()
+	(&Md5Guard{}).Unlock()
+}
+
+func TestSignerMd5Stats(t *testing.T) {
+	s := newFastSigner("")
+	s.Md5Delay = time.Millisecond
+	_, err := s.Sign(context.Background(), "0", "1", "2", "3", "4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	stats := s.Md5Stats()
+	if stats.Acquired != 5 || stats.QueueLen != 0 {
+		t.Errorf("bad stats: %+v", stats)
+	}
+}
+
+func TestDefaultSignerMd5Stats(t *testing.T) {
+	crc32 := DataSignerCrc32
+	defer func() { DataSignerCrc32 = crc32 }()
+	DataSignerCrc32 = crc32Hasher{}.Sum
+
+	before := defaultSigner.Md5Stats()
+	err := ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			for i := 0; i < 5; i++ {
+				out <- i
+			}
+		}),
+		job(SingleHash),
+		job(func(in, out chan interface{}) {
+			for range in {
+			}
+		}),
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// md5 всех пяти значений считаются одновременно и ждут друг друга в очереди
+	stats := defaultSigner.Md5Stats()
+	if stats.Acquired-before.Acquired != 5 || stats.Overheats == before.Overheats {
+		t.Errorf("queue of global SingleHash is not visible: %+v", stats)
+	}
+}
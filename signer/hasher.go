@@ -0,0 +1,125 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Hasher - хеш-функция для схемы подписи
+type Hasher interface {
+	Name() string
+	Sum(data string) string
+}
+
+// HasherFactory создаёт Hasher, key нужен только ключевым алгоритмам вроде HMAC
+type HasherFactory func(key []byte) (Hasher, error)
+
+var (
+	hashersMu sync.RWMutex
+	hashers   = map[string]HasherFactory{}
+)
+
+// RegisterHasher добавляет алгоритм в реестр, повторная регистрация заменяет старый
+func RegisterHasher(name string, factory HasherFactory) {
+	hashersMu.Lock()
+	defer hashersMu.Unlock()
+	hashers[name] = factory
+}
+
+// NewHasher создаёт алгоритм из реестра по имени
+func NewHasher(name string, key []byte) (Hasher, error) {
+	hashersMu.RLock()
+	factory, ok := hashers[name]
+	hashersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown hasher %q", name)
+	}
+	return factory(key)
+}
+
+// Hashers возвращает имена зарегистрированных алгоритмов
+func Hashers() []string {
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+	names := make([]string, 0, len(hashers))
+	for name := range hashers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// исходные алгоритмы задания: md5 в hex и crc32 (IEEE) в десятичном виде
+
+type md5Hasher struct{}
+
+func (md5Hasher) Name() string { return "md5" }
+
+func (md5Hasher) Sum(data string) string {
+	return fmt.Sprintf("%x", md5.Sum([]byte(data)))
+}
+
+type crc32Hasher struct{}
+
+func (crc32Hasher) Name() string { return "crc32" }
+
+func (crc32Hasher) Sum(data string) string {
+	return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(data))), 10)
+}
+
+// hashHasher - алгоритм из стандартной библиотеки, результат в hex
+type hashHasher struct {
+	name    string
+	newHash func() hash.Hash
+}
+
+func (h hashHasher) Name() string { return h.name }
+
+func (h hashHasher) Sum(data string) string {
+	sum := h.newHash()
+	sum.Write([]byte(data))
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func keyless(h Hasher) HasherFactory {
+	return func(key []byte) (Hasher, error) {
+		if len(key) != 0 {
+			return nil, fmt.Errorf("hasher %s does not use a key", h.Name())
+		}
+		return h, nil
+	}
+}
+
+func init() {
+	RegisterHasher("md5", keyless(md5Hasher{}))
+	RegisterHasher("crc32", keyless(crc32Hasher{}))
+	RegisterHasher("crc32c", keyless(hashHasher{name: "crc32c", newHash: func() hash.Hash {
+		return crc32.New(crc32cTable)
+	}}))
+	RegisterHasher("sha256", keyless(hashHasher{name: "sha256", newHash: sha256.New}))
+	RegisterHasher("sha512", keyless(hashHasher{name: "sha512", newHash: sha512.New}))
+	RegisterHasher("fnv", keyless(hashHasher{name: "fnv", newHash: func() hash.Hash {
+		return fnv.New64a()
+	}}))
+	RegisterHasher("hmac-sha256", func(key []byte) (Hasher, error) {
+		if len(key) == 0 {
+			return nil, fmt.Errorf("hmac-sha256 needs a secret key")
+		}
+		key = append([]byte{}, key...)
+		return hashHasher{name: "hmac-sha256", newHash: func() hash.Hash {
+			return hmac.New(sha256.New, key)
+		}}, nil
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestHasherRegistry(t *testing.T) {
+	cases := []struct {
+		name, key, data, expected string
+	}{
+		{"md5", "", "0", "cfcd208495d565ef66e7dff9f98764da"},
+		{"crc32", "", "0", "4108050209"},
+		{"crc32c", "", "123456789", "e3069283"},
+		{"sha256", "", "abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{"sha512", "", "", "cf83e1357eefb8bdf1542850d66d8007d620e4050b5715dc83f4a921d36ce9ce47d0d13c5d85f2b0ff8318d2877eec2f63b931bd47417a81a538327af927da3e"},
+		{"fnv", "", "", "cbf29ce484222325"},
+		{"hmac-sha256", "key", "The quick brown fox jumps over the lazy dog", "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"},
+	}
+	for _, c := range cases {
+		var key []byte
+		if c.key != "" {
+			key = []byte(c.key)
+		}
+		h, err := NewHasher(c.name, key)
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", c.name, err)
+			continue
+		}
+		if got := h.Sum(c.data); got != c.expected || h.Name() != c.name {
+			t.Errorf("%v(%q) = %v; expected %v", h.Name(), c.data, got, c.expected)
+		}
+	}
+
+	if _, err := NewHasher("whirlpool", nil); err == nil {
+		t.Errorf("expected error for unknown hasher")
+	}
+	if _, err := NewHasher("hmac-sha256", nil); err == nil {
+		t.Errorf("expected error for hmac without key")
+	}
+	if _, err := NewHasher("sha256", []byte("key")); err == nil {
+		t.Errorf("expected error for key on keyless hasher")
+	}
+	if names := strings.Join(Hashers(), ","); names != "crc32,crc32c,fnv,hmac-sha256,md5,sha256,sha512" {
+		t.Errorf("bad hasher list: %v", names)
+	}
+}
+
+func TestSignerWithHMAC(t *testing.T) {
+	sign := func(secret string) string {
+		inner, _ := NewHasher("sha256", nil)
+		outer, err := NewHasher("hmac-sha256", []byte(secret))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		s := NewSignerWith(SignerConfig{Inner: inner, Outer: outer, Rounds: 2, SingleSep: ":", CombineSep: "|"})
+		res, err := s.Sign(context.Background(), "0", "1")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return res
+	}
+
+	first := sign("secret")
+	if first != sign("secret") {
+		t.Errorf("signature must be deterministic")
+	}
+	if first == sign("other secret") {
+		t.Errorf("different secrets must give different signatures")
+	}
+
+	parts := strings.Split(first, "|")
+	// два раунда hmac-sha256 по 64 hex-символа на каждое значение
+	if len(parts) != 2 || len(parts[0]) != 2*64 || len(parts[1]) != 2*64 {
+		t.Errorf("bad signature layout: %v", first)
+	}
+}
+
+func TestSignerWithDefaultConfig(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+	res, err := NewSignerWith(SignerConfig{}).Sign(context.Background(), "0", "1")
+	if err != nil || res != testExpected {
+		t.Errorf("empty config must keep original scheme\nGot: %v (%v)\nExpected: %v", res, err, testExpected)
+	}
+}
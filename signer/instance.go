@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// globalOverheat идёт через OverheatLock/OverheatUnlock, чтобы их подмена действовала на defaultSigner
+type globalOverheat struct{}
+
+func (globalOverheat) Lock()   { OverheatLock() }
+func (globalOverheat) Unlock() { OverheatUnlock() }
+
+// Signer хранит всё, что нужно для расчёта подписи: соль, хеш-функции и защиту md5.
+// Разные Signer не делят состояние, так что конвейеры с разной солью можно гонять одновременно.
+type Signer struct {
+	Salt string
+	// Md5 и Crc32 - сами хеш-функции, без соли, задержек и защиты
+	Md5   func(data string) string
+	Crc32 func(data string) string
+	// сколько "считаются" хеши
+	Md5Delay   time.Duration
+	Crc32Delay time.Duration
+	// Rounds - сколько хешей считает MultiHash, SingleSep и CombineSep - разделители
+	// в SingleHash и в итоговой подписи
+	Rounds     int
+	SingleSep  string
+	CombineSep string
+	// Clock отсчитывает задержки, по умолчанию - обычное время
+	Clock Clock
+	// Pipeline - настройки конвейеров Sign, SignMerkle и VerifyStream, например метрики
+	Pipeline PipelineConfig
+	// Retry - таймаут и повторы каждого вызова crc32. Значения, для которых SingleHash
+	// или MultiHash так и не посчитались, уходят в DeadLetters (Value - вход этого звена)
+	// и в подпись не попадают. Без DeadLetters такая ошибка останавливает конвейер
+	Retry       RetryPolicy
+	DeadLetters func(DeadLetter[string])
+
+	guard sync.Locker
+	// md5Guard - откуда брать статистику. У defaultSigner guard идёт через OverheatLock,
+	// а статистика - очередь глобального SingleHash, где md5 и ждут
+	md5Guard *Md5Guard
+	// saltFn нужен только defaultSigner, который берёт соль из DataSignerSalt
+	saltFn func() string
+}
+
+// SignerConfig описывает схему подписи:
+// SingleHash = Outer(data) + SingleSep + Outer(Inner(data)),
+// MultiHash = Outer("0"+single) + ... + Outer(Rounds-1+single),
+// подпись - отсортированные MultiHash через CombineSep.
+// Inner - аналог md5, считается строго по одному. Пустые поля берутся из исходной схемы.
+type SignerConfig struct {
+	Inner      Hasher
+	Outer      Hasher
+	Rounds     int
+	SingleSep  string
+	CombineSep string
+	// Salt дописывается к данным как раньше, для HMAC не нужна
+	Salt string
+	// Md5Delay и Crc32Delay имитируют медленные вычисления из задания, по умолчанию их нет
+	Md5Delay   time.Duration
+	Crc32Delay time.Duration
+	// Clock - для задержек и очереди md5, в тестах удобно подставить VirtualClock
+	Clock Clock
+	// Pipeline - настройки запуска конвейеров подписи
+	Pipeline PipelineConfig
+	// Retry и DeadLetters - как у Signer
+	Retry       RetryPolicy
+	DeadLetters func(DeadLetter[string])
+}
+
+// NewSignerWith собирает Signer по конфигу
+func NewSignerWith(cfg SignerConfig) *Signer {
+	s := NewSigner(cfg.Salt)
+	if cfg.Inner != nil {
+		s.Md5 = cfg.Inner.Sum
+	}
+	if cfg.Outer != nil {
+		s.Crc32 = cfg.Outer.Sum
+	}
+	if cfg.Rounds > 0 {
+		s.Rounds = cfg.Rounds
+	}
+	if cfg.SingleSep != "" {
+		s.SingleSep = cfg.SingleSep
+	}
+	if cfg.CombineSep != "" {
+		s.CombineSep = cfg.CombineSep
+	}
+	s.Md5Delay = cfg.Md5Delay
+	s.Crc32Delay = cfg.Crc32Delay
+	if cfg.Clock != nil {
+		s.Clock = cfg.Clock
+		s.md5Guard.Clock = cfg.Clock
+	}
+	s.Pipeline = cfg.Pipeline
+	s.Retry = cfg.Retry
+	s.DeadLetters = cfg.DeadLetters
+	return s
+}
+
+// NewSigner возвращает Signer с исходными md5/crc32 и задержками как у DataSignerMd5/DataSignerCrc32
+func NewSigner(salt string) *Signer {
+	s := &Signer{
+		Salt:       salt,
+		Md5:        md5Hasher{}.Sum,
+		Crc32:      crc32Hasher{}.Sum,
+		Md5Delay:   10 * time.Millisecond,
+		Crc32Delay: time.Second,
+		Rounds:     6,
+		SingleSep:  "~",
+		CombineSep: "_",
+		Clock:      SystemClock,
+	}
+	s.md5Guard = &Md5Guard{}
+	s.guard = s.md5Guard
+	return s
+}
+
+var (
+	// defaultOverheat - очередь к md5 у глобального SingleHash, overheatGuard
+	// стоит за OverheatLock и защищает прямые вызовы DataSignerMd5 в обход конвейера
+	defaultOverheat = &Md5Guard{}
+	overheatGuard   = &Md5Guard{}
+	defaultSigner   = newDefaultSigner()
+)
+
+func newDefaultSigner() *Signer {
+	s := NewSigner("")
+	s.guard = globalOverheat{}
+	s.md5Guard = defaultOverheat
+	s.saltFn = func() string { return DataSignerSalt }
+	return s
+}
+
+func (s *Signer) salt() string {
+	if s.saltFn != nil {
+		return s.saltFn()
+	}
+	return s.Salt
+}
+
+func (s *Signer) DataSignerMd5(data string) string {
+	s.guard.Lock()
+	defer s.guard.Unlock()
+	dataHash := s.Md5(data + s.salt())
+	clockOrSystem(s.Clock).Sleep(s.Md5Delay)
+	return dataHash
+}
+
+// Md5Stats - статистика очереди к md5 этого Signer
+func (s *Signer) Md5Stats() GuardStats {
+	return s.md5Guard.Stats()
+}
+
+func (s *Signer) DataSignerCrc32(data string) string {
+	dataHash := s.Crc32(data + s.salt())
+	clockOrSystem(s.Clock).Sleep(s.Crc32Delay)
+	return dataHash
+}
+
+func (s *Signer) funcs() signFuncs {
+	// очередь к md5 держит сам guard, отдельный мьютекс не нужен
+	retry := s.Retry
+	if retry.Clock == nil {
+		retry.Clock = s.Clock
+	}
+	return signFuncs{md5: s.DataSignerMd5, crc32: s.DataSignerCrc32, rounds: s.Rounds, singleSep: s.SingleSep, retry: retry, dead: s.DeadLetters}
+}
+
+func (s *Signer) SingleHashStage() Stage[string, string] {
+	return s.funcs().singleHashStage()
+}
+
+func (s *Signer) MultiHashStage() Stage[string, string] {
+	return s.funcs().multiHashStage()
+}
+
+func (s *Signer) CombineResultsStage() Stage[string, string] {
+	return combineResultsStage(s.CombineSep)
+}
+
+func (s *Signer) SingleHash(in, out chan interface{}) {
+	runStringStage(s.SingleHashStage(), in, out)
+}
+
+func (s *Signer) MultiHash(in, out chan interface{}) {
+	runStringStage(s.MultiHashStage(), in, out)
+}
+
+// Sign считает подпись для набора данных целиком: SingleHash -> MultiHash -> CombineResults
+func (s *Signer) Sign(ctx context.Context, data ...string) (string, error) {
+	chain := Then(Then(Then(FromSlice(data...), s.SingleHashStage()), s.MultiHashStage()), s.CombineResultsStage())
+	res, err := chain.With(s.Pipeline).Collect(ctx)
+	if err != nil {
+		return "", err
+	}
+	return res[0], nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newFastSigner(salt string) *Signer {
+	s := NewSigner(salt)
+	s.Md5Delay = 0
+	s.Crc32Delay = 0
+	return s
+}
+
+// expectedSign считает подпись линейно, по описанию из задания
+func expectedSign(s *Signer, data ...string) []string {
+	res := make([]string, 0, len(data))
+	for _, d := range data {
+		single := s.Crc32(d+s.Salt) + "~" + s.Crc32(s.Md5(d+s.Salt)+s.Salt)
+		multi := ""
+		for th := 0; th < 6; th++ {
+			multi += s.Crc32(strconv.Itoa(th) + single + s.Salt)
+		}
+		res = append(res, multi)
+	}
+	return res
+}
+
+func TestSignerNoSalt(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+	res, err := newFastSigner("").Sign(context.Background(), "0", "1")
+	if err != nil || res != testExpected {
+		t.Errorf("results not match\nGot: %v (%v)\nExpected: %v", res, err, testExpected)
+	}
+}
+
+func TestSignersWithDifferentSalt(t *testing.T) {
+	salts := []string{"alpha", "beta", "gamma"}
+	results := make([]string, len(salts))
+	var wg sync.WaitGroup
+	for i, salt := range salts {
+		wg.Add(1)
+		go func(i int, salt string) {
+			defer wg.Done()
+			res, err := newFastSigner(salt).Sign(context.Background(), "0", "1", "2")
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results[i] = res
+		}(i, salt)
+	}
+	wg.Wait()
+
+	for i, salt := range salts {
+		hashes := expectedSign(newFastSigner(salt), "0", "1", "2")
+		found := 0
+		for _, h := range hashes {
+			for _, part := range strings.Split(results[i], "_") {
+				if part == h {
+					found++
+					break
+				}
+			}
+		}
+		if found != len(hashes) {
+			t.Errorf("signature for salt %v does not match\nGot: %v\nExpected parts: %v", salt, results[i], hashes)
+		}
+	}
+	if results[0] == results[1] || results[1] == results[2] {
+		t.Errorf("different salts must give different signatures")
+	}
+}
+
+func TestDefaultSignerUsesGlobalSalt(t *testing.T) {
+	orig := DataSignerSalt
+	origDelay := defaultSigner.Crc32Delay
+	defaultSigner.Crc32Delay = 0
+	defer func() {
+		DataSignerSalt = orig
+		defaultSigner.Crc32Delay = origDelay
+	}()
+
+	DataSignerSalt = "salt"
+	// сам DataSignerCrc32 могли подменить другие тесты
+	if defaultSigner.DataSignerCrc32("0") != (crc32Hasher{}).Sum("0salt") {
+		t.Errorf("defaultSigner must use DataSignerSalt")
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// ErrJournalMismatch - журнал записан для других входных данных
+var ErrJournalMismatch = errors.New("journal does not match input")
+
+// ErrJournalIncomplete - часть значений ушла в DeadLetters, подпись не записана
+var ErrJournalIncomplete = errors.New("journal is incomplete")
+
+// Journal - файл, куда дописывается MultiHash каждого посчитанного значения,
+// а в конце - сама подпись. По нему прерванная подпись продолжается с того же места.
+type Journal struct {
+	// Sync - сбрасывать каждую запись на диск. Медленнее, но переживает и падение системы,
+	// а не только процесса
+	Sync bool
+
+	mu     sync.Mutex
+	f      *os.File
+	hashes map[int]journalRecord
+	done   *journalRecord
+}
+
+// запись журнала - одна строка JSON
+type journalRecord struct {
+	Index int    `json:"i"`
+	Input string `json:"in,omitempty"`
+	Hash  string `json:"hash,omitempty"`
+	// последняя запись: подпись Count значений готова
+	Done  bool   `json:"done,omitempty"`
+	Count int    `json:"n,omitempty"`
+	Sig   string `json:"sig,omitempty"`
+}
+
+// OpenJournal открывает журнал или создаёт новый. Недописанная последняя строка
+// (процесс упал посреди записи) отрезается.
+func OpenJournal(path string) (*Journal, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	j := &Journal{f: f, hashes: map[int]journalRecord{}}
+	if err := j.load(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Journal) load() error {
+	r := bufio.NewReader(j.f)
+	var good int64
+	for line := 1; ; line++ {
+		data, err := r.ReadString('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var rec journalRecord
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			return fmt.Errorf("journal line %d: %w", line, err)
+		}
+		if rec.Done {
+			j.done = &rec
+		} else {
+			j.hashes[rec.Index] = rec
+		}
+		good += int64(len(data))
+	}
+	if err := j.f.Truncate(good); err != nil {
+		return err
+	}
+	_, err := j.f.Seek(good, io.SeekStart)
+	return err
+}
+
+func (j *Journal) Close() error {
+	return j.f.Close()
+}
+
+func (j *Journal) append(rec journalRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// строка пишется одним вызовом, при падении может остаться только её начало
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if rec.Done {
+		j.done = &rec
+	} else {
+		j.hashes[rec.Index] = rec
+	}
+	if j.Sync {
+		return j.f.Sync()
+	}
+	return nil
+}
+
+// check проверяет, что всё записанное в журнале относится к data
+func (j *Journal) check(data []string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, rec := range j.hashes {
+		if i < 0 || i >= len(data) || data[i] != rec.Input {
+			return fmt.Errorf("%w: value %d", ErrJournalMismatch, i)
+		}
+	}
+	if j.done != nil && j.done.Count != len(data) {
+		return fmt.Errorf("%w: journal is done for %d values, got %d", ErrJournalMismatch, j.done.Count, len(data))
+	}
+	return nil
+}
+
+// SignResumable считает подпись как Sign, записывая каждый MultiHash в журнал.
+// После падения повторный вызов с тем же журналом и теми же данными досчитывает
+// только то, чего в журнале нет, а если подпись уже была готова - сразу возвращает её.
+// Значения, ушедшие в DeadLetters, не записываются и считаются заново при повторе,
+// а пока их нет, подпись не записывается и возвращается ErrJournalIncomplete.
+func (s *Signer) SignResumable(ctx context.Context, j *Journal, data ...string) (string, error) {
+	if err := j.check(data); err != nil {
+		return "", err
+	}
+	if j.done != nil {
+		return j.done.Sig, nil
+	}
+
+	var todo []indexed
+	for i, d := range data {
+		if _, ok := j.hashes[i]; !ok {
+			todo = append(todo, indexed{index: i, input: d, value: d})
+		}
+	}
+
+	// номер значения идёт вместе с ним, чтобы пропуски в DeadLetters не сбили журнал
+	f := s.funcs()
+	chain := Then(Then(FromSlice(todo...), f.indexedStage(f.withDeadLetter(f.singleHash))), f.indexedStage(f.withDeadLetter(f.multiHash)))
+	err := chain.With(s.Pipeline).Run(ctx, func(it indexed) error {
+		return j.append(journalRecord{Index: it.index, Input: it.input, Hash: it.value})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if missing := len(data) - len(j.hashes); missing > 0 {
+		return "", fmt.Errorf("%w: %d of %d values are missing", ErrJournalIncomplete, missing, len(data))
+	}
+	hashes := make([]string, 0, len(data))
+	for i := range data {
+		hashes = append(hashes, j.hashes[i].Hash)
+	}
+	sig := combine(s.CombineSep, hashes)
+	if err := j.append(journalRecord{Done: true, Count: len(data), Sig: sig}); err != nil {
+		return "", err
+	}
+	return sig, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var journalData = []string{"in0", "in1", "in2", "in3", "in4", "in5", "in6"}
+
+// countingSigner считает, сколько значений дошло до SingleHash
+func countingSigner() (*Signer, *int32) {
+	s := newFastSigner("")
+	var calls int32
+	crc32 := s.Crc32
+	s.Crc32 = func(data string) string {
+		if strings.HasPrefix(data, "in") {
+			atomic.AddInt32(&calls, 1)
+		}
+		return crc32(data)
+	}
+	return s, &calls
+}
+
+func TestJournalResume(t *testing.T) {
+	expected, err := newFastSigner("").Sign(context.Background(), journalData...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "journal")
+
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, calls := countingSigner()
+	res, err := s.SignResumable(context.Background(), j, journalData...)
+	j.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res != expected || *calls != 7 {
+		t.Fatalf("first run: got %v after %d values\nExpected: %v", res, *calls, expected)
+	}
+
+	// падение после трёх значений посреди записи четвёртого
+	content, _ := os.ReadFile(path)
+	lines := strings.SplitAfter(string(content), "\n")
+	if len(lines) != 9 {
+		t.Fatalf("expected 7 values and signature in journal, got:\n%s", content)
+	}
+	torn := strings.Join(lines[:3], "") + lines[3][:10]
+	if err := os.WriteFile(path, []byte(torn), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s, calls = countingSigner()
+	res, err = s.SignResumable(context.Background(), j, journalData...)
+	j.Close()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res != expected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", res, expected)
+	}
+	if *calls != 4 {
+		t.Errorf("only 4 values must be recomputed, got %d", *calls)
+	}
+
+	// подпись готова - повтор ничего не считает
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer j.Close()
+	s, calls = countingSigner()
+	res, err = s.SignResumable(context.Background(), j, journalData...)
+	if err != nil || res != expected || *calls != 0 {
+		t.Errorf("done journal: got %v, %v after %d values", res, err, *calls)
+	}
+}
+
+func TestJournalMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := newFastSigner("").SignResumable(context.Background(), j, "a", "b"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	j.Close()
+
+	j, err = OpenJournal(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer j.Close()
+	if _, err := newFastSigner("").SignResumable(context.Background(), j, "a", "c"); !errors.Is(err, ErrJournalMismatch) {
+		t.Errorf("expected ErrJournalMismatch, got %v", err)
+	}
+}
+
+func TestJournalCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal")
+	if err := os.WriteFile(path, []byte("{\"i\":0}\nnot json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenJournal(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error for line 2, got %v", err)
+	}
+}
+
+func TestJournalDeadLetters(t *testing.T) {
+	expected, err := newFastSigner("").Sign(context.Background(), journalData...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "journal")
+	j, err := OpenJournal(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer j.Close()
+
+	// сервис подписи не отвечает на одно значение
+	s, _ := countingSigner()
+	crc32 := s.Crc32
+	s.Crc32 = func(data string) string {
+		if data == "in3" {
+			panic("service is down")
+		}
+		return crc32(data)
+	}
+	var dead int32
+	s.Retry = RetryPolicy{Attempts: 2}
+	s.DeadLetters = func(DeadLetter[string]) { atomic.AddInt32(&dead, 1) }
+	if _, err := s.SignResumable(context.Background(), j, journalData...); !errors.Is(err, ErrJournalIncomplete) || dead != 1 {
+		t.Fatalf("expected ErrJournalIncomplete after 1 dead letter, got %v after %d", err, dead)
+	}
+
+	// сервис ожил - досчитывается только пропавшее значение
+	s, calls := countingSigner()
+	res, err := s.SignResumable(context.Background(), j, journalData...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res != expected || *calls != 1 {
+		t.Errorf("got %v after %d values\nExpected: %v", res, *calls, expected)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// Overflow - что делать звену, когда очередь на его выходе полна
+type Overflow int
+
+const (
+	// OverflowBlock - ждать, пока следующее звено заберёт значение, как раньше
+	OverflowBlock Overflow = iota
+	// OverflowDropOldest - выбросить самое старое значение из очереди и поставить новое
+	OverflowDropOldest
+	// OverflowDropNewest - выбросить новое значение
+	OverflowDropNewest
+	// OverflowError - остановить конвейер с ErrQueueFull
+	OverflowError
+)
+
+// ErrQueueFull - очередь с OverflowError переполнилась, приходит обёрнутой в *StageError звена-отправителя
+var ErrQueueFull = errors.New("queue is full")
+
+const defaultLinkCapacity = 100
+
+// LinkConfig - очередь между звеном и следующим
+type LinkConfig struct {
+	// Capacity - сколько результатов звена может ждать следующего, 0 - как раньше, 100
+	Capacity int
+	// Unbuffered - очереди нет, звено ждёт, пока следующее заберёт значение
+	Unbuffered bool
+	Overflow   Overflow
+}
+
+func (c PipelineConfig) link(index int) LinkConfig {
+	if l, ok := c.Links[index]; ok {
+		return l
+	}
+	return c.Link
+}
+
+func (l LinkConfig) capacity() int {
+	switch {
+	case l.Unbuffered:
+		return 0
+	case l.Capacity > 0:
+		return l.Capacity
+	}
+	return defaultLinkCapacity
+}
+
+// link соединяет звено index со звеном down: звено пишет в out, down читает next.
+// Обычно это один и тот же канал. С метриками или политикой, отличной от OverflowBlock,
+// между ними стоит relay - тогда в очереди всегда есть хотя бы одно место.
+// down < 0 - читает не одно звено, простои читателя не считаются.
+func link[T any](g *runGroup, index int, name string, down int) (out, next chan T) {
+	cfg := g.cfg.link(index)
+	capacity := cfg.capacity()
+	m := g.cfg.Metrics
+	if m == nil && cfg.Overflow == OverflowBlock {
+		ch := make(chan T, capacity)
+		g.onCancel(func() {
+			for range ch {
+			}
+		})
+		return ch, ch
+	}
+
+	if capacity == 0 {
+		capacity = 1
+	}
+	r := &relay[T]{g: g, index: index, name: name, capacity: capacity, overflow: cfg.Overflow, clock: SystemClock}
+	if m != nil {
+		r.clock = clockOrSystem(m.Clock)
+		r.up = m.stage(index)
+		if down >= 0 {
+			r.down = m.stage(down)
+		}
+	}
+	out, next = make(chan T), make(chan T)
+	g.goHelper(func() { r.run(out, next) })
+	return out, next
+}
+
+// relay стоит между звеньями вместо общего канала: держит очередь результатов звена up,
+// отдаёт их звену down, применяет Overflow и считает значения и простои обоих.
+// Звено ждёт отправки, только если очередь полна, и ждёт чтения, только если она пуста,
+// поэтому простой засчитывается, если после освобождения места (появления значения)
+// другая сторона забрала его сразу.
+type relay[T any] struct {
+	g        *runGroup
+	index    int
+	name     string
+	capacity int
+	overflow Overflow
+	clock    Clock
+	up, down *stageMetrics
+
+	queue      []T
+	fullSince  time.Time
+	emptySince time.Time
+}
+
+func (r *relay[T]) push(val T, since time.Time) {
+	now := r.clock.Now()
+	r.up.emitted(now, since)
+	if len(r.queue) == r.capacity {
+		switch r.overflow {
+		case OverflowDropOldest:
+			r.queue = r.queue[1:]
+		case OverflowDropNewest:
+			r.up.drop()
+			return
+		case OverflowError:
+			r.g.fail(&StageError{Index: r.index, Name: r.name, Err: ErrQueueFull})
+			r.up.drop()
+			return
+		}
+		r.up.drop()
+	}
+	r.queue = append(r.queue, val)
+	if len(r.queue) == r.capacity {
+		r.fullSince = now
+	}
+}
+
+func (r *relay[T]) pop(since time.Time) {
+	r.queue = r.queue[1:]
+	now := r.clock.Now()
+	r.down.taken(now, since)
+	if len(r.queue) == 0 {
+		r.emptySince = now
+	}
+}
+
+func (r *relay[T]) run(from <-chan T, to chan<- T) {
+	defer close(to)
+	r.emptySince = r.clock.Now()
+	r.up.setQueue(0, r.capacity)
+
+	for from != nil || len(r.queue) > 0 {
+		var (
+			recv <-chan T
+			send chan<- T
+			head T
+		)
+		// кроме OverflowBlock полная очередь не останавливает отправителя
+		if from != nil && (len(r.queue) < r.capacity || r.overflow != OverflowBlock) {
+			recv = from
+		}
+		if len(r.queue) > 0 {
+			send, head = to, r.queue[0]
+		}
+
+		select {
+		case val, ok := <-recv:
+			if !ok {
+				from = nil
+				break
+			}
+			wasEmpty := len(r.queue) == 0
+			r.push(val, time.Time{})
+			if !wasEmpty || len(r.queue) == 0 {
+				break
+			}
+			// очередь была пуста - если получатель уже ждёт, он ждал с emptySince
+			select {
+			case to <- r.queue[0]:
+				r.pop(r.emptySince)
+			default:
+			}
+		case send <- head:
+			full := len(r.queue) == r.capacity
+			r.pop(time.Time{})
+			if !full || from == nil || r.overflow != OverflowBlock {
+				break
+			}
+			// очередь была полна - если отправитель уже ждёт, он ждал с fullSince
+			select {
+			case val, ok := <-from:
+				if !ok {
+					from = nil
+					break
+				}
+				r.push(val, r.fullSince)
+			default:
+			}
+		case <-r.g.ctx.Done():
+			r.up.setQueue(0, r.capacity)
+			if from != nil {
+				for range from {
+				}
+			}
+			return
+		}
+		r.up.setQueue(len(r.queue), r.capacity)
+	}
+}
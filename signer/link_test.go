@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// runOverflow - источник отправляет 1..10, пока следующее звено ничего не читает
+func runOverflow(cfg PipelineConfig) ([]int, error) {
+	done := make(chan struct{})
+	var res []int
+	err := ExecutePipelineConfig(context.Background(), cfg,
+		func(ctx context.Context, in, out chan interface{}) error {
+			defer close(done)
+			for i := 1; i <= 10; i++ {
+				out <- i
+			}
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			<-done
+			for val := range in {
+				res = append(res, val.(int))
+			}
+			return nil
+		},
+	)
+	return res, err
+}
+
+func TestLinkOverflow(t *testing.T) {
+	cases := []struct {
+		name     string
+		overflow Overflow
+		expected []int
+	}{
+		{"drop newest", OverflowDropNewest, []int{1, 2, 3}},
+		{"drop oldest", OverflowDropOldest, []int{8, 9, 10}},
+	}
+	for _, c := range cases {
+		m := NewMetrics()
+		res, err := runOverflow(PipelineConfig{
+			Metrics: m,
+			Links:   map[int]LinkConfig{0: {Capacity: 3, Overflow: c.overflow}},
+		})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.name, err)
+		}
+		if !reflect.DeepEqual(res, c.expected) {
+			t.Errorf("%s: got %v, expected %v", c.name, res, c.expected)
+		}
+		if stats := m.Stages()[0]; stats.Dropped != 7 || stats.ItemsOut != 10 || stats.QueueCap != 3 {
+			t.Errorf("%s: bad stats %+v", c.name, stats)
+		}
+	}
+}
+
+func TestLinkOverflowError(t *testing.T) {
+	_, err := runOverflow(PipelineConfig{Link: LinkConfig{Capacity: 3, Overflow: OverflowError}})
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Index != 0 || !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull of stage 0, got %v", err)
+	}
+}
+
+func TestLinkCapacity(t *testing.T) {
+	cases := []struct {
+		name     string
+		link     LinkConfig
+		expected int32
+	}{
+		{"default", LinkConfig{}, 20},
+		{"capacity", LinkConfig{Capacity: 2}, 2},
+		{"unbuffered", LinkConfig{Unbuffered: true}, 0},
+	}
+	for _, c := range cases {
+		var sent int32
+		release := make(chan struct{})
+		chain := Then(Source(func(ctx context.Context, out chan<- int) error {
+			for i := 0; i < 20; i++ {
+				out <- i
+				atomic.AddInt32(&sent, 1)
+			}
+			return nil
+		}), func(ctx context.Context, in <-chan int, out chan<- int) error {
+			<-release
+			for val := range in {
+				out <- val
+			}
+			return nil
+		})
+
+		errc := make(chan error, 1)
+		go func() {
+			res, err := chain.With(PipelineConfig{Links: map[int]LinkConfig{0: c.link}}).Collect(context.Background())
+			if err == nil && len(res) != 20 {
+				t.Errorf("%s: lost values: %v", c.name, res)
+			}
+			errc <- err
+		}()
+
+		// источник успевает отправить ровно столько, сколько помещается в очередь
+		time.Sleep(50 * time.Millisecond)
+		if got := atomic.LoadInt32(&sent); got != c.expected {
+			t.Errorf("%s: sent %d before consumer started, expected %d", c.name, got, c.expected)
+		}
+		close(release)
+		if err := <-errc; err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.name, err)
+		}
+	}
+}
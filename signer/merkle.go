@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// листья и узлы хешируются с разными префиксами, чтобы узел нельзя было выдать за лист
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+func merkleLeaf(item string) []byte {
+	sum := sha256.Sum256(append([]byte{merkleLeafPrefix}, item...))
+	return sum[:]
+}
+
+func merkleNode(left, right []byte) []byte {
+	buf := make([]byte, 0, 1+len(left)+len(right))
+	buf = append(buf, merkleNodePrefix)
+	buf = append(buf, left...)
+	buf = append(buf, right...)
+	sum := sha256.Sum256(buf)
+	return sum[:]
+}
+
+// MerkleTree строится над отсортированными MultiHash, поэтому корень, как и CombineResults,
+// не зависит от порядка значений. Непарный узел уровня поднимается наверх без изменений.
+type MerkleTree struct {
+	items  []string
+	levels [][][]byte
+}
+
+// ProofStep - соседний узел на пути к корню, Left - сосед стоит слева
+type ProofStep struct {
+	Hash string
+	Left bool
+}
+
+// MerkleProof доказывает, что Item входит в дерево с известным корнем
+type MerkleProof struct {
+	Item  string
+	Steps []ProofStep
+}
+
+func NewMerkleTree(items []string) *MerkleTree {
+	sorted := append([]string{}, items...)
+	sort.Strings(sorted)
+
+	level := make([][]byte, len(sorted))
+	for i, item := range sorted {
+		level[i] = merkleLeaf(item)
+	}
+	t := &MerkleTree{items: sorted, levels: [][][]byte{level}}
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+		t.levels = append(t.levels, next)
+		level = next
+	}
+	return t
+}
+
+// Root - корень дерева в hex, у пустого дерева - sha256 от пустой строки
+func (t *MerkleTree) Root() string {
+	top := t.levels[len(t.levels)-1]
+	if len(top) == 0 {
+		sum := sha256.Sum256(nil)
+		return hex.EncodeToString(sum[:])
+	}
+	return hex.EncodeToString(top[0])
+}
+
+// Proof строит доказательство включения для item (MultiHash одного значения)
+func (t *MerkleTree) Proof(item string) (MerkleProof, error) {
+	index := sort.SearchStrings(t.items, item)
+	if index == len(t.items) || t.items[index] != item {
+		return MerkleProof{}, fmt.Errorf("item %s is not in the tree", item)
+	}
+	proof := MerkleProof{Item: item}
+	for _, level := range t.levels[:len(t.levels)-1] {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			proof.Steps = append(proof.Steps, ProofStep{
+				Hash: hex.EncodeToString(level[sibling]),
+				Left: sibling < index,
+			})
+		}
+		index /= 2
+	}
+	return proof, nil
+}
+
+// VerifyProof проверяет доказательство относительно корня, само дерево для этого не нужно
+func VerifyProof(root string, proof MerkleProof) bool {
+	hash := merkleLeaf(proof.Item)
+	for _, step := range proof.Steps {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false
+		}
+		if step.Left {
+			hash = merkleNode(sibling, hash)
+		} else {
+			hash = merkleNode(hash, sibling)
+		}
+	}
+	return hex.EncodeToString(hash) == root
+}
+
+// MerkleCombineStage - замена CombineResultsStage, которая вместо склейки отдаёт корень дерева
+func MerkleCombineStage(ctx context.Context, in <-chan string, out chan<- string) error {
+	items := make([]string, 0)
+	for data := range in {
+		items = append(items, data)
+	}
+	if !emit(ctx, out, NewMerkleTree(items).Root()) {
+		return ctx.Err()
+	}
+	return nil
+}
+
+func MerkleCombine(in, out chan interface{}) {
+	runStringStage(MerkleCombineStage, in, out)
+}
+
+// ErrItemSkipped - значение ушло в DeadLetters, хеша для него нет
+var ErrItemSkipped = errors.New("item went to dead letters")
+
+// ItemHash считает MultiHash(SingleHash(data)) - то, что попадает в подпись от одного значения
+func (s *Signer) ItemHash(ctx context.Context, data string) (string, error) {
+	res, err := Then(Then(FromSlice(data), s.SingleHashStage()), s.MultiHashStage()).Collect(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(res) == 0 {
+		return "", ErrItemSkipped
+	}
+	return res[0], nil
+}
+
+// SignMerkle считает MultiHash всех значений и строит над ними дерево.
+// Корень - подпись пачки, tree.Proof(ItemHash(value)) - доказательство для одной записи.
+func (s *Signer) SignMerkle(ctx context.Context, data ...string) (*MerkleTree, error) {
+	hashes, err := Then(Then(FromSlice(data...), s.SingleHashStage()), s.MultiHashStage()).With(s.Pipeline).Collect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewMerkleTree(hashes), nil
+}
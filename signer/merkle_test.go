@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestMerkleProofs(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		items := make([]string, size)
+		for i := range items {
+			items[i] = "item" + strconv.Itoa(size-i)
+		}
+		tree := NewMerkleTree(items)
+		root := tree.Root()
+		for _, item := range items {
+			proof, err := tree.Proof(item)
+			if err != nil {
+				t.Fatalf("size %v: unexpected error: %v", size, err)
+			}
+			if !VerifyProof(root, proof) {
+				t.Errorf("size %v: proof for %v rejected", size, item)
+			}
+			forged := proof
+			forged.Item = "forged"
+			if VerifyProof(root, forged) {
+				t.Errorf("size %v: forged proof accepted", size)
+			}
+		}
+		if _, err := tree.Proof("missing"); err == nil {
+			t.Errorf("size %v: expected error for missing item", size)
+		}
+	}
+}
+
+func TestMerkleRootOrderIndependent(t *testing.T) {
+	a := NewMerkleTree([]string{"a", "b", "c"}).Root()
+	b := NewMerkleTree([]string{"c", "a", "b"}).Root()
+	c := NewMerkleTree([]string{"a", "b", "d"}).Root()
+	if a != b {
+		t.Errorf("root must not depend on order")
+	}
+	if a == c {
+		t.Errorf("different items must give different roots")
+	}
+	if NewMerkleTree(nil).Root() != "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" {
+		t.Errorf("bad empty root")
+	}
+}
+
+func TestSignMerkle(t *testing.T) {
+	s := newFastSigner("")
+	ctx := context.Background()
+	inputs := []string{"0", "1", "1", "2", "3", "5", "8"}
+
+	tree, err := s.SignMerkle(ctx, inputs...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	roots, err := Then(Then(Then(FromSlice(inputs...), s.SingleHashStage()), s.MultiHashStage()), MerkleCombineStage).Collect(ctx)
+	if err != nil || len(roots) != 1 || roots[0] != tree.Root() {
+		t.Fatalf("MerkleCombineStage root differs: %v %v", roots, err)
+	}
+
+	hash, _ := s.ItemHash(ctx, "5")
+	proof, err := tree.Proof(hash)
+	if err != nil || !VerifyProof(tree.Root(), proof) {
+		t.Errorf("record 5 must be provable: %v", err)
+	}
+	hash, _ = s.ItemHash(ctx, "4")
+	if _, err := tree.Proof(hash); err == nil {
+		t.Errorf("record 4 is not in batch")
+	}
+}
+
+func TestItemHashDeadLetter(t *testing.T) {
+	s := newFastSigner("")
+	s.Crc32 = func(string) string { panic("hw failure") }
+	s.Retry = RetryPolicy{Attempts: 2}
+	s.DeadLetters = func(DeadLetter[string]) {}
+	if hash, err := s.ItemHash(context.Background(), "1"); !errors.Is(err, ErrItemSkipped) {
+		t.Errorf("expected ErrItemSkipped, got %q, %v", hash, err)
+	}
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// границы корзин гистограммы времени обработки, последняя корзина - всё, что дольше
+var stageLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+	10 * time.Second,
+}
+
+// Metrics собирает счётчики по звеньям одного конвейера. Подключается через
+// PipelineConfig.Metrics и отдаётся в формате Prometheus как http.Handler.
+// Между звеньями тогда стоит посредник, который видит каждое значение,
+// так что без Metrics конвейер работает как раньше.
+type Metrics struct {
+	// Clock - для замеров времени, по умолчанию обычное время
+	Clock Clock
+
+	mu     sync.Mutex
+	stages map[int]*stageMetrics
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{stages: map[int]*stageMetrics{}}
+}
+
+type stageMetrics struct {
+	mu   sync.Mutex
+	name string
+
+	itemsIn     uint64
+	itemsOut    uint64
+	dropped     uint64
+	queueLen    int
+	queueCap    int
+	sendBlocked time.Duration
+	recvBlocked time.Duration
+
+	// mark - когда звено последний раз взяло значение или отдало результат
+	mark time.Time
+	// taken - когда звено брало значения, ещё не ставшие результатом, по порядку.
+	// Передачи на входе и на выходе видят разные relay, и взятие следующего значения
+	// может быть учтено раньше отданного результата - поэтому время обработки
+	// считается по очереди, а не от mark.
+	pending  []time.Time
+	lastSent time.Time
+
+	latencySum  time.Duration
+	latencyHist []uint64
+}
+
+// сколько взятых значений помнить, если звено отдаёт меньше, чем берёт
+const maxPending = 1024
+
+// StageStats - снимок счётчиков одного звена
+type StageStats struct {
+	Index int
+	Name  string
+	// сколько значений звено прочитало и сколько отдало
+	ItemsIn  uint64
+	ItemsOut uint64
+	// Dropped - сколько результатов выброшено из полной очереди по LinkConfig.Overflow
+	Dropped uint64
+	// QueueLen из QueueCap - сколько результатов звена ждут следующего, у звена без буфера QueueCap 1
+	QueueLen int
+	QueueCap int
+	// сколько звено простояло на отправке в полную очередь и на чтении из пустой
+	SendBlocked time.Duration
+	RecvBlocked time.Duration
+	// время от взятого значения (или прошлого результата) до результата,
+	// для звеньев 1:1 это время обработки значения.
+	// Latency[i] - сколько замеров уложилось в (Buckets[i-1], Buckets[i]],
+	// последний элемент - дольше последней границы
+	Buckets    []time.Duration
+	Latency    []uint64
+	LatencySum time.Duration
+}
+
+func (m *Metrics) stage(index int) *stageMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stages == nil {
+		m.stages = map[int]*stageMetrics{}
+	}
+	st, ok := m.stages[index]
+	if !ok {
+		st = &stageMetrics{latencyHist: make([]uint64, len(stageLatencyBuckets)+1)}
+		m.stages[index] = st
+	}
+	return st
+}
+
+// start отмечает запуск звена index
+func (m *Metrics) start(index int, name string) {
+	st := m.stage(index)
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.name = name
+	st.mark = clockOrSystem(m.Clock).Now()
+	st.lastSent = st.mark
+	st.pending = nil
+}
+
+// waitSince - с какого момента звено могло ждать: не раньше since
+// и не раньше, чем оно последний раз что-то взяло или отдало
+func (st *stageMetrics) waitSince(now, since time.Time) time.Duration {
+	if since.IsZero() {
+		return 0
+	}
+	if st.mark.After(since) {
+		since = st.mark
+	}
+	return now.Sub(since)
+}
+
+// taken - звено взяло значение. Ненулевой since - звено ждало его, очередь пуста с since
+func (st *stageMetrics) taken(now, since time.Time) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.itemsIn++
+	st.recvBlocked += st.waitSince(now, since)
+	st.setMark(now)
+	if len(st.pending) == maxPending {
+		st.pending = st.pending[1:]
+	}
+	st.pending = append(st.pending, now)
+}
+
+func (st *stageMetrics) setMark(now time.Time) {
+	if now.After(st.mark) {
+		st.mark = now
+	}
+}
+
+// emitted - звено отдало результат. Ненулевой since - звено ждало места, очередь полна с since
+func (st *stageMetrics) emitted(now, since time.Time) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	wait := st.waitSince(now, since)
+	st.itemsOut++
+	st.sendBlocked += wait
+	// результат - от самого старого взятого значения, у источника - от прошлого результата
+	from := st.lastSent
+	if len(st.pending) > 0 {
+		from = st.pending[0]
+		st.pending = st.pending[1:]
+	}
+	latency := now.Sub(from) - wait
+	if latency < 0 {
+		latency = 0
+	}
+	st.setMark(now)
+	st.lastSent = now
+	st.latencySum += latency
+	i := 0
+	for i < len(stageLatencyBuckets) && latency > stageLatencyBuckets[i] {
+		i++
+	}
+	st.latencyHist[i]++
+}
+
+// drop - результат звена выброшен из полной очереди
+func (st *stageMetrics) drop() {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.dropped++
+}
+
+func (st *stageMetrics) setQueue(length, capacity int) {
+	if st == nil {
+		return
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.queueLen, st.queueCap = length, capacity
+}
+
+// Stages возвращает снимок по всем звеньям, по порядку
+func (m *Metrics) Stages() []StageStats {
+	m.mu.Lock()
+	indexes := make([]int, 0, len(m.stages))
+	for i := range m.stages {
+		indexes = append(indexes, i)
+	}
+	m.mu.Unlock()
+	sort.Ints(indexes)
+
+	res := make([]StageStats, 0, len(indexes))
+	for _, i := range indexes {
+		st := m.stage(i)
+		st.mu.Lock()
+		if st.name == "" {
+			// за последним звеном никого нет, а relay заводит счётчики и для него
+			st.mu.Unlock()
+			continue
+		}
+		stats := StageStats{
+			Index:       i,
+			Name:        st.name,
+			ItemsIn:     st.itemsIn,
+			ItemsOut:    st.itemsOut,
+			Dropped:     st.dropped,
+			QueueLen:    st.queueLen,
+			QueueCap:    st.queueCap,
+			SendBlocked: st.sendBlocked,
+			RecvBlocked: st.recvBlocked,
+			Buckets:     append([]time.Duration{}, stageLatencyBuckets...),
+			Latency:     append([]uint64{}, st.latencyHist...),
+			LatencySum:  st.latencySum,
+		}
+		st.mu.Unlock()
+		res = append(res, stats)
+	}
+	return res
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WritePrometheus пишет счётчики в текстовом формате Prometheus
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	stages := m.Stages()
+	var b strings.Builder
+	labels := func(st StageStats) string {
+		return fmt.Sprintf(`stage="%d",name="%s"`, st.Index, labelEscaper.Replace(st.Name))
+	}
+	metric := func(name, typ, help string, value func(st StageStats) string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+		for _, st := range stages {
+			fmt.Fprintf(&b, "%s{%s} %s\n", name, labels(st), value(st))
+		}
+	}
+
+	metric("signer_stage_items_in_total", "counter", "Items received by the stage.", func(st StageStats) string {
+		return fmt.Sprint(st.ItemsIn)
+	})
+	metric("signer_stage_items_out_total", "counter", "Items sent by the stage.", func(st StageStats) string {
+		return fmt.Sprint(st.ItemsOut)
+	})
+	metric("signer_stage_dropped_total", "counter", "Items dropped from the full output queue.", func(st StageStats) string {
+		return fmt.Sprint(st.Dropped)
+	})
+	metric("signer_stage_queue_length", "gauge", "Items waiting in the stage output queue.", func(st StageStats) string {
+		return fmt.Sprint(st.QueueLen)
+	})
+	metric("signer_stage_queue_capacity", "gauge", "Capacity of the stage output queue.", func(st StageStats) string {
+		return fmt.Sprint(st.QueueCap)
+	})
+	metric("signer_stage_send_blocked_seconds_total", "counter", "Time the stage spent blocked on a full output queue.", func(st StageStats) string {
+		return fmt.Sprint(st.SendBlocked.Seconds())
+	})
+	metric("signer_stage_recv_blocked_seconds_total", "counter", "Time the stage spent waiting for input.", func(st StageStats) string {
+		return fmt.Sprint(st.RecvBlocked.Seconds())
+	})
+
+	const latency = "signer_stage_latency_seconds"
+	fmt.Fprintf(&b, "# HELP %s Time from taking an item to sending a result.\n# TYPE %s histogram\n", latency, latency)
+	for _, st := range stages {
+		var count uint64
+		for i, n := range st.Latency {
+			count += n
+			le := "+Inf"
+			if i < len(st.Buckets) {
+				le = fmt.Sprint(st.Buckets[i].Seconds())
+			}
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%s\"} %d\n", latency, labels(st), le, count)
+		}
+		fmt.Fprintf(&b, "%s_sum{%s} %v\n", latency, labels(st), st.LatencySum.Seconds())
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", latency, labels(st), count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WritePrometheus(w)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitStages ждёт, пока счётчики звеньев дойдут до нужных. Время в relay отмечают
+// сами, часы про них не знают, поэтому двигать время можно только после этого.
+func waitStages(m *Metrics, ready func(s []StageStats) bool) {
+	for {
+		if ready(m.Stages()) {
+			return
+		}
+		runtime.Gosched()
+	}
+}
+
+func TestMetricsCounters(t *testing.T) {
+	clock := NewVirtualClock(virtualStart)
+	m := &Metrics{Clock: clock}
+	// waiting - сколько раз последнее звено встало ждать значение
+	var waiting uint64
+	go func() {
+		// время двигается, только когда медленное звено спит на i-м значении,
+		// а последнее забрало прошлое и ждёт следующее
+		for i := uint64(0); i < 5; i++ {
+			clock.BlockUntil(1)
+			waitStages(m, func(s []StageStats) bool {
+				return len(s) == 3 && s[0].ItemsOut == 5 && s[1].ItemsIn == i+1 && s[1].ItemsOut == i && s[2].ItemsIn == i &&
+					atomic.LoadUint64(&waiting) == i+1
+			})
+			clock.Advance(100 * time.Millisecond)
+		}
+	}()
+
+	var sum int
+	err := ExecutePipelineConfig(context.Background(), PipelineConfig{Metrics: m},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := 1; i <= 5; i++ {
+				out <- i
+			}
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for val := range in {
+				clock.Sleep(100 * time.Millisecond)
+				out <- val.(int) * 2
+			}
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for {
+				atomic.AddUint64(&waiting, 1)
+				val, ok := <-in
+				if !ok {
+					return nil
+				}
+				sum += val.(int)
+			}
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sum != 30 {
+		t.Errorf("bad sum: %d", sum)
+	}
+
+	stages := m.Stages()
+	if len(stages) != 3 {
+		t.Fatalf("expected 3 stages, got %+v", stages)
+	}
+	for i, want := range [][2]uint64{{0, 5}, {5, 5}, {5, 0}} {
+		if stages[i].ItemsIn != want[0] || stages[i].ItemsOut != want[1] {
+			t.Errorf("stage %d: bad counters in=%d out=%d, expected %v", i, stages[i].ItemsIn, stages[i].ItemsOut, want)
+		}
+		if stages[i].QueueLen != 0 || stages[i].QueueCap != 100 {
+			t.Errorf("stage %d: bad queue %d/%d", i, stages[i].QueueLen, stages[i].QueueCap)
+		}
+	}
+	// все 5 обработок по 100мс попадают в корзину (10мс, 100мс]
+	if got := stages[1].Latency[2]; got != 5 || stages[1].LatencySum != 500*time.Millisecond {
+		t.Errorf("bad latency of slow stage: %v, sum %s", stages[1].Latency, stages[1].LatencySum)
+	}
+	// последнее звено всё время ждёт медленное
+	if got := stages[2].RecvBlocked; got < 400*time.Millisecond || got > 500*time.Millisecond {
+		t.Errorf("bad recv blocked of last stage: %s", got)
+	}
+	if stages[0].SendBlocked != 0 {
+		t.Errorf("source must not block, got %s", stages[0].SendBlocked)
+	}
+}
+
+func TestMetricsSendBlocked(t *testing.T) {
+	clock := NewVirtualClock(virtualStart)
+	m := &Metrics{Clock: clock}
+	go func() {
+		for i := uint64(0); i < 150; i++ {
+			// очередь на 100 перед медленным звеном полна, пока источнику есть что слать
+			sent := i + 101
+			if sent > 150 {
+				sent = 150
+			}
+			clock.BlockUntil(1)
+			waitStages(m, func(s []StageStats) bool {
+				return len(s) == 3 && s[0].ItemsOut == sent && s[1].ItemsIn == i+1 && s[1].ItemsOut == i && s[2].ItemsIn == i
+			})
+			clock.Advance(time.Millisecond)
+		}
+	}()
+
+	chain := Then(Source(func(ctx context.Context, out chan<- int) error {
+		for i := 0; i < 150; i++ {
+			out <- i
+		}
+		return nil
+	}), func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			clock.Sleep(time.Millisecond)
+			out <- val
+		}
+		return nil
+	})
+	res, err := chain.With(PipelineConfig{Metrics: m}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 150 {
+		t.Fatalf("lost values: %d", len(res))
+	}
+
+	stages := m.Stages()
+	if len(stages) != 3 || stages[2].Name != "sink" {
+		t.Fatalf("bad stages: %+v", stages)
+	}
+	// источник упирается в очередь на 100 и ждёт, пока медленное звено разберёт лишние 50
+	if got := stages[0].SendBlocked; got < 40*time.Millisecond || got > 60*time.Millisecond {
+		t.Errorf("bad send blocked of source: %s", got)
+	}
+	if got := stages[1].RecvBlocked; got > 2*time.Millisecond {
+		t.Errorf("slow stage must not wait for input, got %s", got)
+	}
+}
+
+func TestMetricsPrometheus(t *testing.T) {
+	m := NewMetrics()
+	s := newFastSigner("")
+	s.Pipeline = PipelineConfig{Metrics: m}
+	if _, err := s.Sign(context.Background(), "0", "1", "1", "2", "3", "5", "8"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("bad content type: %q", ct)
+	}
+
+	body := rec.Body.String()
+	stages := m.Stages()
+	if len(stages) != 5 {
+		t.Fatalf("expected source, 3 stages and sink, got %+v", stages)
+	}
+	single := `stage="1",name="` + stages[1].Name + `"`
+	for _, line := range []string{
+		"# TYPE signer_stage_items_in_total counter",
+		"signer_stage_items_in_total{" + single + "} 7",
+		"signer_stage_items_out_total{" + single + "} 7",
+		`signer_stage_items_in_total{stage="4",name="sink"} 1`,
+		"signer_stage_queue_capacity{" + single + "} 100",
+		"# TYPE signer_stage_latency_seconds histogram",
+		"signer_stage_latency_seconds_bucket{" + single + `,le="+Inf"} 7`,
+		"signer_stage_latency_seconds_count{" + single + "} 7",
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("no line %q in output:\n%s", line, body)
+		}
+	}
+}
+
+func TestMetricsCancel(t *testing.T) {
+	m := NewMetrics()
+	errStop := errors.New("stop")
+	err := ExecutePipelineConfig(context.Background(), PipelineConfig{Metrics: m},
+		func(ctx context.Context, in, out chan interface{}) error {
+			// обычное звено без ctx: после отмены его отправки должны вычитываться
+			for i := 0; i < 1000; i++ {
+				out <- i
+			}
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for val := range in {
+				if val.(int) == 10 {
+					return errStop
+				}
+			}
+			return nil
+		},
+	)
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Index != 1 || !errors.Is(err, errStop) {
+		t.Fatalf("expected error of stage 1, got %v", err)
+	}
+	if stages := m.Stages(); stages[0].QueueLen != 0 {
+		t.Errorf("queue must be empty after cancel, got %d", stages[0].QueueLen)
+	}
+}
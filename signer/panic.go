@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicPolicy - что делать, если звено запаниковало
+type PanicPolicy int
+
+const (
+	// PanicStop - остановить конвейер, паника вернётся как *PanicError внутри *StageError
+	PanicStop PanicPolicy = iota
+	// PanicSkip - пропустить значение, на котором звено упало, и запустить звено заново
+	// на остальном входе. Состояние звена при этом теряется, так что это для звеньев,
+	// которые обрабатывают значения независимо. Если звено паникует, не взяв ни одного
+	// нового значения (например источник), конвейер останавливается как при PanicStop.
+	PanicSkip
+)
+
+// PanicError - паника звена, превращённая в ошибку
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+	// Item - значение, на котором звено упало (для ParallelMap - то, на котором упала fn),
+	// или последнее, которое звено взяло до паники. Есть только при PanicSkip
+	Item    interface{}
+	HasItem bool
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v\n\n%s", e.Value, e.Stack)
+}
+
+// stageFailure - ошибка звена, которую job без возвращаемого значения пробрасывает паникой
+type stageFailure struct {
+	err error
+}
+
+// recoverRun вызывает run и превращает панику в *PanicError, а stageFailure - в его ошибку
+func recoverRun(run func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if f, ok := r.(stageFailure); ok {
+				err = f.err
+				return
+			}
+			err = &PanicError{Value: r, Stack: debug.Stack()}
+		}
+	}()
+	return run()
+}
+
+type panicSkipKey struct{}
+
+// panicSkipper - куда звено при PanicSkip сообщает о пропущенной панике на одном значении,
+// не падая целиком. nil - PanicSkip не задан
+func panicSkipper(ctx context.Context) func(p *PanicError) {
+	skip, _ := ctx.Value(panicSkipKey{}).(func(p *PanicError))
+	return skip
+}
+
+// isolate при PanicSkip перезапускает run после паники, пока звено берёт новые значения.
+// Вход идёт через посредника, чтобы знать, сколько значений звено взяло и какое последним.
+// В ctx для run лежит panicSkipper - звенья, которые сами знают, на каком значении упали
+// (ParallelMap), пропускают его без перезапуска.
+func isolate[T any](g *runGroup, index int, name string, in chan T, run func(ctx context.Context, in chan T) error) func() error {
+	if g.cfg.Panic != PanicSkip {
+		return func() error { return run(g.ctx, in) }
+	}
+	onPanic := func(p *PanicError) {
+		if g.cfg.OnPanic != nil {
+			g.cfg.OnPanic(&StageError{Index: index, Name: name, Err: p})
+		}
+	}
+	ctx := context.WithValue(g.ctx, panicSkipKey{}, onPanic)
+	type taken struct {
+		count int
+		last  T
+	}
+	return func() error {
+		fwd := make(chan T)
+		// query отвечает из того же select, что и отправка - значение, которое звено
+		// успело забрать до паники, в ответе точно учтено
+		query := make(chan chan taken)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			var (
+				st      taken
+				pending T
+				has     bool
+				closed  bool
+			)
+			for {
+				var (
+					recv <-chan T
+					send chan T
+				)
+				switch {
+				case has:
+					send = fwd
+				case in != nil:
+					recv = in
+				case !closed:
+					close(fwd)
+					closed = true
+				}
+				select {
+				case val, ok := <-recv:
+					if !ok {
+						in = nil
+						break
+					}
+					pending, has = val, true
+				case send <- pending:
+					st.count, st.last = st.count+1, pending
+					has = false
+				case q := <-query:
+					q <- st
+				case <-done:
+					// звено закончило, не дочитав вход - вычитываем, чтобы не держать предыдущее
+					if in != nil {
+						for range in {
+						}
+					}
+					return
+				}
+			}
+		}()
+		state := func() taken {
+			q := make(chan taken)
+			query <- q
+			return <-q
+		}
+
+		for {
+			before := state()
+			err := recoverRun(func() error { return run(ctx, fwd) })
+
+			var p *PanicError
+			if !errors.As(err, &p) {
+				return err
+			}
+			after := state()
+			if after.count == before.count {
+				return err
+			}
+			p.Item, p.HasItem = after.last, true
+			onPanic(p)
+		}
+	}
+}
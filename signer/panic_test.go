@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPanicStop(t *testing.T) {
+	err := ExecutePipelineContext(context.Background(),
+		func(ctx context.Context, in, out chan interface{}) {
+			for i := 0; i < 1000; i++ {
+				out <- "not a number"
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) {
+			for val := range in {
+				out <- val.(uint32) * 3
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) {
+			for range in {
+			}
+		},
+	)
+
+	var stageErr *StageError
+	var panicErr *PanicError
+	if !errors.As(err, &stageErr) || stageErr.Index != 1 || !errors.As(err, &panicErr) {
+		t.Fatalf("expected panic of stage 1, got %v", err)
+	}
+	if !strings.Contains(string(panicErr.Stack), "TestPanicStop") {
+		t.Errorf("stack does not point to the stage:\n%s", panicErr.Stack)
+	}
+	if panicErr.HasItem {
+		t.Errorf("item is known only with PanicSkip, got %v", panicErr.Item)
+	}
+}
+
+func TestPanicSkip(t *testing.T) {
+	var skipped []*StageError
+	var recieved uint32
+	err := ExecutePipelineConfig(context.Background(), PipelineConfig{
+		Panic:   PanicSkip,
+		OnPanic: func(err *StageError) { skipped = append(skipped, err) },
+	},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for _, val := range []interface{}{uint32(1), "bad", uint32(3), "worse", uint32(4)} {
+				out <- val
+			}
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for val := range in {
+				out <- val.(uint32) * 3
+			}
+			return nil
+		},
+		func(ctx context.Context, in, out chan interface{}) error {
+			for val := range in {
+				atomic.AddUint32(&recieved, val.(uint32))
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if recieved != (1+3+4)*3 {
+		t.Errorf("bad sum of good values: %d", recieved)
+	}
+	if len(skipped) != 2 {
+		t.Fatalf("expected 2 skipped values, got %v", skipped)
+	}
+	for i, item := range []string{"bad", "worse"} {
+		var p *PanicError
+		if skipped[i].Index != 1 || !errors.As(skipped[i], &p) || !p.HasItem || p.Item != item {
+			t.Errorf("bad skipped value %d: %v", i, skipped[i])
+		}
+	}
+}
+
+func TestPanicSkipNoProgress(t *testing.T) {
+	_, err := Source(func(ctx context.Context, out chan<- int) error {
+		panic("source is broken")
+	}).With(PipelineConfig{Panic: PanicSkip}).Collect(context.Background())
+
+	var p *PanicError
+	if !errors.As(err, &p) || p.Value != "source is broken" {
+		t.Fatalf("expected panic of source, got %v", err)
+	}
+}
+
+func TestPanicSkipTyped(t *testing.T) {
+	chain := Then(FromSlice(1, 2, 0, 4), func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for val := range in {
+			out <- 12 / val
+		}
+		return nil
+	})
+	res, err := chain.With(PipelineConfig{Panic: PanicSkip}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 3 || res[0] != 12 || res[1] != 6 || res[2] != 3 {
+		t.Errorf("bad result: %v", res)
+	}
+}
+
+func TestPanicParallelMap(t *testing.T) {
+	_, err := MapSlice(context.Background(), 4, []int{1, 2, 0, 4}, func(ctx context.Context, val int) (int, error) {
+		return 12 / val, nil
+	})
+	var p *PanicError
+	if !errors.As(err, &p) {
+		t.Fatalf("expected panic as error, got %v", err)
+	}
+}
+
+func TestPanicSkipParallelMap(t *testing.T) {
+	in := make([]int, 10)
+	for i := range in {
+		in[i] = i
+	}
+	var skipped []*StageError
+	var mu sync.Mutex
+	chain := Then(FromSlice(in...), ParallelMap(4, func(ctx context.Context, val int) (int, error) {
+		if val == 6 {
+			panic("bad value")
+		}
+		return val, nil
+	}, true))
+	res, err := chain.With(PipelineConfig{
+		Panic: PanicSkip,
+		OnPanic: func(err *StageError) {
+			mu.Lock()
+			skipped = append(skipped, err)
+			mu.Unlock()
+		},
+	}).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// значения, которые были в работе вместе с упавшим, не теряются
+	if fmt.Sprint(res) != "[0 1 2 3 4 5 7 8 9]" {
+		t.Errorf("bad result: %v", res)
+	}
+	var p *PanicError
+	if len(skipped) != 1 || skipped[0].Index != 1 || !errors.As(skipped[0], &p) || p.Item != 6 {
+		t.Errorf("bad skipped values: %v", skipped)
+	}
+}
+
+func TestSignCrc32Panic(t *testing.T) {
+	for _, policy := range []PanicPolicy{PanicStop, PanicSkip} {
+		s := newFastSigner("")
+		s.Crc32 = func(string) string { panic("hw failure") }
+		s.Pipeline = PipelineConfig{Panic: policy}
+		res, err := s.Sign(context.Background(), "1", "2")
+		var p *PanicError
+		switch {
+		case policy == PanicStop && !errors.As(err, &p):
+			t.Errorf("expected panic as error, got %q, %v", res, err)
+		case policy == PanicSkip && (err != nil || res != ""):
+			t.Errorf("expected every value skipped, got %q, %v", res, err)
+		}
+	}
+}
+
+func TestSignPanicSkipMultiHashRound(t *testing.T) {
+	plain := newFastSigner("")
+	single, err := Then(FromSlice("1"), plain.SingleHashStage()).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var skipped int32
+	s := newFastSigner("")
+	// падает только один раунд MultiHash для "1" - значение должно выпасть целиком
+	s.Crc32 = func(data string) string {
+		if data == "2"+single[0] {
+			panic("hw failure")
+		}
+		return plain.Crc32(data)
+	}
+	s.Pipeline = PipelineConfig{
+		Panic:   PanicSkip,
+		OnPanic: func(err *StageError) { atomic.AddInt32(&skipped, 1) },
+	}
+	res, err := s.Sign(context.Background(), "1", "2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := strings.Join(expectedSign(plain, "2"), "_"); res != expected {
+		t.Errorf("expected %q, got %q", expected, res)
+	}
+	if skipped != 1 {
+		t.Errorf("expected 1 skipped value, got %v", skipped)
+	}
+}
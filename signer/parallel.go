@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// ParallelMap - звено, которое применяет fn к значениям в workers горутинах.
+// При ordered результаты отдаются в порядке входа, иначе - по мере готовности.
+// Одновременно в работе не больше workers значений (и столько же готовых, ждущих очереди),
+// первая ошибка или паника fn останавливает звено. При PanicSkip паника fn пропускает
+// только своё значение, остальные продолжают считаться.
+func ParallelMap[In, Out any](workers int, fn func(ctx context.Context, val In) (Out, error), ordered bool) Stage[In, Out] {
+	if workers < 1 {
+		workers = 1
+	}
+	type result struct {
+		val Out
+		err error
+	}
+	type task struct {
+		val In
+		res chan result
+	}
+
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		skip := panicSkipper(ctx)
+		tasks := make(chan task)
+		// pending - очередь результатов в порядке входа, results - в порядке готовности
+		pending := make(chan chan result, workers)
+		results := make(chan result, workers)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer close(tasks)
+			defer close(pending)
+			for {
+				var val In
+				var ok bool
+				select {
+				case val, ok = <-in:
+				case <-ctx.Done():
+					return
+				}
+				if !ok {
+					return
+				}
+				t := task{val: val, res: make(chan result, 1)}
+				select {
+				case tasks <- t:
+				case <-ctx.Done():
+					return
+				}
+				if ordered {
+					select {
+					case pending <- t.res:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+
+		var workersWg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			workersWg.Add(1)
+			go func() {
+				defer workersWg.Done()
+				for t := range tasks {
+					var val Out
+					err := recoverRun(func() (err error) {
+						val, err = fn(ctx, t.val)
+						return err
+					})
+					if p, ok := err.(*PanicError); ok && skip != nil {
+						p.Item, p.HasItem = t.val, true
+						skip(p)
+						err = errSkip
+					}
+					if ordered {
+						t.res <- result{val: val, err: err}
+						continue
+					}
+					select {
+					case results <- result{val: val, err: err}:
+					case <-ctx.Done():
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workersWg.Wait()
+			close(results)
+		}()
+		// при выходе дожидаемся всех своих горутин, чтобы после звена ничего не осталось
+		defer wg.Wait()
+		defer cancel()
+
+		collect := func(r result) error {
+			if r.err == errSkip {
+				return nil
+			}
+			if r.err != nil {
+				return r.err
+			}
+			if !emit(ctx, out, r.val) {
+				return ctx.Err()
+			}
+			return nil
+		}
+
+		if ordered {
+			for res := range pending {
+				var r result
+				select {
+				case r = <-res:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+				if err := collect(r); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for r := range results {
+			if err := collect(r); err != nil {
+				return err
+			}
+		}
+		return ctx.Err()
+	}
+}
+
+// MapSlice считает fn для всех значений параллельно и возвращает результаты в том же порядке.
+// Паника fn - ошибка всего MapSlice, PanicSkip звена, внутри которого он вызван, на неё не действует
+func MapSlice[In, Out any](ctx context.Context, workers int, vals []In, fn func(ctx context.Context, val In) (Out, error)) ([]Out, error) {
+	in := make(chan In, len(vals))
+	for _, val := range vals {
+		in <- val
+	}
+	close(in)
+	out := make(chan Out, len(vals))
+	// без panicSkipper в ctx пропущенное значение просто выпало бы из результата
+	err := ParallelMap(workers, fn, true)(context.WithValue(ctx, panicSkipKey{}, nil), in, out)
+	close(out)
+	res := make([]Out, 0, len(vals))
+	for val := range out {
+		res = append(res, val)
+	}
+	return res, err
+}
+
+// ParallelJob - ParallelMap для обычных звеньев на interface{}
+func ParallelJob(workers int, fn func(ctx context.Context, val interface{}) (interface{}, error), ordered bool) jobErr {
+	stage := ParallelMap(workers, fn, ordered)
+	return func(ctx context.Context, in, out chan interface{}) error {
+		return stage(ctx, in, out)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParallelMapOrdered(t *testing.T) {
+	var running, maxRunning int32
+	square := func(ctx context.Context, val int) (int, error) {
+		cur := atomic.AddInt32(&running, 1)
+		for {
+			prev := atomic.LoadInt32(&maxRunning)
+			if cur <= prev || atomic.CompareAndSwapInt32(&maxRunning, prev, cur) {
+				break
+			}
+		}
+		// первые значения считаются дольше, чтобы обогнать их было легко
+		time.Sleep(time.Duration(20-val%20) * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return val * val, nil
+	}
+
+	vals := make([]int, 50)
+	for i := range vals {
+		vals[i] = i
+	}
+	res, err := Then(FromSlice(vals...), ParallelMap(8, square, true)).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, val := range res {
+		if val != i*i {
+			t.Fatalf("result %v out of order: %v", i, res)
+		}
+	}
+	if maxRunning > 8 {
+		t.Errorf("expected at most 8 workers, got %v", maxRunning)
+	}
+}
+
+func TestParallelMapUnordered(t *testing.T) {
+	slow := func(ctx context.Context, val int) (int, error) {
+		if val == 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		return val, nil
+	}
+	res, err := Then(FromSlice(0, 1, 2, 3), ParallelMap(4, slow, false)).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 4 || res[3] != 0 {
+		t.Errorf("slow value must come last, got %v", res)
+	}
+	sort.Ints(res)
+	for i, val := range res {
+		if val != i {
+			t.Errorf("lost values: %v", res)
+		}
+	}
+}
+
+func TestParallelMapError(t *testing.T) {
+	errBad := errors.New("bad")
+	for _, ordered := range []bool{true, false} {
+		fn := func(ctx context.Context, val int) (int, error) {
+			if val == 3 {
+				return 0, errBad
+			}
+			return val, nil
+		}
+		source := Source(func(ctx context.Context, out chan<- int) error {
+			for i := 0; ; i++ {
+				if !emit(ctx, out, i) {
+					return ctx.Err()
+				}
+			}
+		})
+		_, err := Then(source, ParallelMap(4, fn, ordered)).Collect(context.Background())
+		if !errors.Is(err, errBad) {
+			t.Errorf("ordered=%v: expected errBad, got %v", ordered, err)
+		}
+	}
+}
+
+func TestParallelJob(t *testing.T) {
+	var sum uint32
+	err := ExecutePipelineErr(context.Background(),
+		func(ctx context.Context, in, out chan interface{}) error {
+			for i := uint32(1); i <= 10; i++ {
+				send(ctx, out, i)
+			}
+			return nil
+		},
+		ParallelJob(3, func(ctx context.Context, val interface{}) (interface{}, error) {
+			return val.(uint32) * 2, nil
+		}, false),
+		func(ctx context.Context, in, out chan interface{}) error {
+			for val := range in {
+				atomic.AddUint32(&sum, val.(uint32))
+			}
+			return nil
+		},
+	)
+	if err != nil || sum != 110 {
+		t.Errorf("expected sum 110, got %v, %v", sum, err)
+	}
+}
+
+func TestMapSlice(t *testing.T) {
+	res, err := MapSlice(context.Background(), 3, []string{"a", "b", "c"}, func(ctx context.Context, val string) (string, error) {
+		return val + val, nil
+	})
+	if err != nil || len(res) != 3 || res[0] != "aa" || res[2] != "cc" {
+		t.Errorf("bad result: %v, %v", res, err)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// jobContext - звено конвейера, которое знает про отмену и должно завершиться по ctx.Done()
+type jobContext func(ctx context.Context, in, out chan interface{})
+
+// jobErr - звено, которое может сообщить об ошибке. Ошибка останавливает весь конвейер
+type jobErr func(ctx context.Context, in, out chan interface{}) error
+
+type stage struct {
+	name string
+	run  jobErr
+}
+
+// StageError - ошибка звена с его номером (с нуля) и именем функции
+type StageError struct {
+	Index int
+	Name  string
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("stage %d (%s): %v", e.Index, e.Name, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// funcName возвращает имя функции без пакета, для замыканий получится что-то вроде TestX.func1
+func funcName(f interface{}) string {
+	name := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.Index(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// send отправляет значение дальше, если конвейер ещё не отменён
+func send(ctx context.Context, out chan interface{}, val interface{}) bool {
+	select {
+	case out <- val:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func drain(ch chan interface{}) {
+	for range ch {
+	}
+}
+
+// ExecutePipelineContext запускает звенья как ExecutePipeline, но при отмене ctx
+// вычитывает все каналы, чтобы никто не завис на отправке, дожидается завершения звеньев
+// и возвращает ctx.Err()
+func ExecutePipelineContext(ctx context.Context, jobs ...jobContext) error {
+	stages := make([]stage, 0, len(jobs))
+	for _, j := range jobs {
+		j := j
+		stages = append(stages, stage{name: funcName(j), run: func(ctx context.Context, in, out chan interface{}) error {
+			j(ctx, in, out)
+			return nil
+		}})
+	}
+	return executeStages(ctx, PipelineConfig{}, stages)
+}
+
+// ExecutePipelineErr - то же для звеньев, которые возвращают ошибку. Первая ошибка отменяет
+// остальные звенья и возвращается обёрнутой в *StageError
+func ExecutePipelineErr(ctx context.Context, jobs ...jobErr) error {
+	stages := make([]stage, 0, len(jobs))
+	for _, j := range jobs {
+		stages = append(stages, stage{name: funcName(j), run: j})
+	}
+	return executeStages(ctx, PipelineConfig{}, stages)
+}
+
+// PipelineConfig - необязательные настройки запуска конвейера
+type PipelineConfig struct {
+	// Metrics - куда считать значения и простои звеньев
+	Metrics *Metrics
+	// Link - очередь на выходе каждого звена, Links - для отдельных звеньев по номеру
+	Link  LinkConfig
+	Links map[int]LinkConfig
+	// Panic - что делать с паникой звена, OnPanic узнаёт о каждой пропущенной при PanicSkip
+	Panic   PanicPolicy
+	OnPanic func(err *StageError)
+}
+
+// ExecutePipelineConfig - ExecutePipelineErr с настройками
+func ExecutePipelineConfig(ctx context.Context, cfg PipelineConfig, jobs ...jobErr) error {
+	stages := make([]stage, 0, len(jobs))
+	for _, j := range jobs {
+		stages = append(stages, stage{name: funcName(j), run: j})
+	}
+	return executeStages(ctx, cfg, stages)
+}
+
+// runGroup следит за горутинами звеньев: первая ошибка отменяет контекст,
+// после отмены все каналы вычитываются, чтобы никто не завис на отправке
+type runGroup struct {
+	parent context.Context
+	cfg    PipelineConfig
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	firstErr error
+	drains   []func()
+}
+
+func newRunGroup(parent context.Context, cfg PipelineConfig) *runGroup {
+	ctx, cancel := context.WithCancel(parent)
+	return &runGroup{parent: parent, cfg: cfg, ctx: ctx, cancel: cancel}
+}
+
+func (g *runGroup) fail(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	// ошибки после отмены - уже следствие, а не причина
+	if g.firstErr == nil && g.ctx.Err() == nil {
+		g.firstErr = err
+		g.cancel()
+	}
+}
+
+// onCancel регистрирует вычитку канала, которая запустится при отмене
+func (g *runGroup) onCancel(drain func()) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.drains = append(g.drains, drain)
+}
+
+func (g *runGroup) goStage(index int, name string, run func() error) {
+	if g.cfg.Metrics != nil {
+		g.cfg.Metrics.start(index, name)
+	}
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := recoverRun(run); err != nil {
+			g.fail(&StageError{Index: index, Name: name, Err: err})
+		}
+	}()
+}
+
+// goHelper запускает служебную горутину, которую тоже надо дождаться
+func (g *runGroup) goHelper(fn func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn()
+	}()
+}
+
+// wait дожидается всех звеньев и возвращает первую ошибку или ошибку родительского контекста
+func (g *runGroup) wait() error {
+	defer g.cancel()
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-g.ctx.Done():
+		g.mu.Lock()
+		for _, d := range g.drains {
+			go d()
+		}
+		g.mu.Unlock()
+		<-done
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.firstErr != nil {
+		return g.firstErr
+	}
+	return g.parent.Err()
+}
+
+func executeStages(parent context.Context, cfg PipelineConfig, stages []stage) error {
+	g := newRunGroup(parent, cfg)
+	// первому звену читать нечего, закрываем вход сразу
+	in := make(chan interface{}, 100)
+	close(in)
+
+	for i, s := range stages {
+		out, next := link[interface{}](g, i, s.name, i+1)
+		stageRun := s.run
+		run := isolate(g, i, s.name, in, func(ctx context.Context, in chan interface{}) error {
+			return stageRun(ctx, in, out)
+		})
+		g.goStage(i, s.name, func() error {
+			defer close(out)
+			return run()
+		})
+		in = next
+	}
+	// выход последнего звена никто не читает
+	go drain(in)
+
+	return g.wait()
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPipelineContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var recieved uint32
+	jobs := []jobContext{
+		// бесконечный источник
+		func(ctx context.Context, in, out chan interface{}) {
+			for i := 0; ; i++ {
+				if !send(ctx, out, i) {
+					return
+				}
+			}
+		},
+		// звено, которое про контекст не знает и может зависнуть на отправке
+		func(ctx context.Context, in, out chan interface{}) {
+			for val := range in {
+				out <- val
+			}
+		},
+		// потребитель, который сдаётся после 5 значений
+		func(ctx context.Context, in, out chan interface{}) {
+			for range in {
+				if atomic.AddUint32(&recieved, 1) == 5 {
+					cancel()
+					return
+				}
+			}
+		},
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- ExecutePipelineContext(ctx, jobs...)
+	}()
+
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pipeline did not stop after cancel")
+	}
+	if recieved != 5 {
+		t.Errorf("expected 5 collected values, got %d", recieved)
+	}
+}
+
+func TestPipelineContextTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := ExecutePipelineContext(ctx,
+		func(ctx context.Context, in, out chan interface{}) {
+			<-ctx.Done()
+		},
+		func(ctx context.Context, in, out chan interface{}) {
+			for range in {
+			}
+		},
+	)
+	if err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if end := time.Since(start); end > 500*time.Millisecond {
+		t.Errorf("execition too long\nGot: %s", end)
+	}
+}
+
+func TestPipelineContextDone(t *testing.T) {
+	var sum uint32
+	err := ExecutePipelineContext(context.Background(),
+		func(ctx context.Context, in, out chan interface{}) {
+			for i := uint32(1); i <= 3; i++ {
+				send(ctx, out, i)
+			}
+		},
+		func(ctx context.Context, in, out chan interface{}) {
+			for val := range in {
+				atomic.AddUint32(&sum, val.(uint32))
+			}
+		},
+	)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if sum != 6 {
+		t.Errorf("expected sum 6, got %d", sum)
+	}
+}
+
+func failingStage(ctx context.Context, in, out chan interface{}) error {
+	for val := range in {
+		if val.(int) == 3 {
+			return errBadValue
+		}
+		send(ctx, out, val)
+	}
+	return nil
+}
+
+var errBadValue = errors.New("bad value")
+
+func TestPipelineErrFirstError(t *testing.T) {
+	var sourceStopped uint32
+	err := ExecutePipelineErr(context.Background(),
+		func(ctx context.Context, in, out chan interface{}) error {
+			defer atomic.StoreUint32(&sourceStopped, 1)
+			for i := 0; ; i++ {
+				if !send(ctx, out, i) {
+					return ctx.Err()
+				}
+			}
+		},
+		failingStage,
+		func(ctx context.Context, in, out chan interface{}) error {
+			for range in {
+			}
+			return nil
+		},
+	)
+
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) {
+		t.Fatalf("expected *StageError, got %v", err)
+	}
+	if stageErr.Index != 1 || stageErr.Name != "failingStage" || !errors.Is(err, errBadValue) {
+		t.Errorf("bad stage error: %+v", stageErr)
+	}
+	if err.Error() != "stage 1 (failingStage): bad value" {
+		t.Errorf("bad error text: %v", err)
+	}
+	if atomic.LoadUint32(&sourceStopped) != 1 {
+		t.Errorf("source must be stopped after error")
+	}
+}
+
+func TestExecutePipelineNoError(t *testing.T) {
+	err := ExecutePipeline(
+		job(func(in, out chan interface{}) {
+			out <- 1
+		}),
+		job(func(in, out chan interface{}) {
+			for range in {
+			}
+		}),
+	)
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestExecutePipelineHashError(t *testing.T) {
+	md5, crc32 := DataSignerMd5, DataSignerCrc32
+	defer func() { DataSignerMd5, DataSignerCrc32 = md5, crc32 }()
+	DataSignerMd5 = func(data string) string { panic("hw failure") }
+	DataSignerCrc32 = crc32Hasher{}.Sum
+
+	// входа больше, чем влезает в буферы между звеньями, - источник не должен зависнуть
+	done := make(chan error, 1)
+	go func() {
+		done <- ExecutePipeline(
+			job(func(in, out chan interface{}) {
+				for i := 0; i < 2000; i++ {
+					out <- i
+				}
+			}),
+			job(SingleHash),
+			job(MultiHash),
+			job(CombineResults),
+			job(func(in, out chan interface{}) {
+				for range in {
+				}
+			}),
+		)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline hangs")
+	}
+	var stageErr *StageError
+	var p *PanicError
+	if !errors.As(err, &stageErr) || stageErr.Name != "SingleHash" || !errors.As(err, &p) {
+		t.Fatalf("expected panic of SingleHash, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// ErrAttemptTimeout - попытка не уложилась в RetryPolicy.Timeout
+var ErrAttemptTimeout = errors.New("attempt timed out")
+
+// RetryPolicy - сколько раз и как часто повторять вызов для одного значения
+type RetryPolicy struct {
+	// Attempts - сколько всего попыток, 0 и 1 - без повторов
+	Attempts int
+	// Timeout - на одну попытку. Зависший вызов бросается, его ctx отменяется
+	Timeout time.Duration
+	// Backoff - пауза перед второй попыткой, дальше она удваивается, но не больше MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Jitter - доля паузы от 0 до 1, на которую она случайно укорачивается,
+	// чтобы повторы разных значений не шли одновременно
+	Jitter float64
+	// Clock - для таймаутов и пауз, по умолчанию обычное время
+	Clock Clock
+}
+
+// RetryError - вызов не удался за Attempts попыток, Err - ошибка последней
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+func (p RetryPolicy) enabled() bool {
+	return p.Attempts > 1 || p.Timeout > 0
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(float64(d) * p.Jitter * rand.Float64())
+	}
+	return d
+}
+
+// attempt вызывает fn один раз, паника считается ошибкой попытки.
+// Брошенная по таймауту попытка отдаёт значение только в свой канал, который уже никто не читает
+func attempt[T any](ctx context.Context, p RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	call := func(ctx context.Context) (val T, err error) {
+		err = recoverRun(func() (err error) {
+			val, err = fn(ctx)
+			return err
+		})
+		return val, err
+	}
+	if p.Timeout <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		val T
+		err error
+	}
+	res := make(chan result, 1)
+	go func() {
+		val, err := call(ctx)
+		res <- result{val, err}
+	}()
+	select {
+	case r := <-res:
+		return r.val, r.err
+	case <-clockOrSystem(p.Clock).After(p.Timeout):
+		var zero T
+		return zero, ErrAttemptTimeout
+	}
+}
+
+// retryDo - Do, который возвращает значение удачной попытки
+func retryDo[T any](ctx context.Context, p RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	var zero T
+	attempts := p.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	var err error
+	for i := 1; i <= attempts; i++ {
+		if i > 1 {
+			select {
+			case <-clockOrSystem(p.Clock).After(p.backoff(i - 1)):
+			case <-ctx.Done():
+				return zero, ctx.Err()
+			}
+		}
+		var val T
+		if val, err = attempt(ctx, p, fn); err == nil {
+			return val, nil
+		}
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+		err = &RetryError{Attempts: i, Err: err}
+	}
+	return zero, err
+}
+
+// Do вызывает fn, пока она не вернёт nil, не кончатся попытки или не отменят ctx.
+// Ошибка после всех попыток - *RetryError.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := retryDo(ctx, p, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+	return err
+}
+
+// Retry оборачивает функцию значения для ParallelMap политикой повторов
+func Retry[In, Out any](p RetryPolicy, fn func(ctx context.Context, val In) (Out, error)) func(ctx context.Context, val In) (Out, error) {
+	return func(ctx context.Context, val In) (Out, error) {
+		return retryDo(ctx, p, func(ctx context.Context) (Out, error) {
+			return fn(ctx, val)
+		})
+	}
+}
+
+// DeadLetter - значение, которое не удалось обработать
+type DeadLetter[T any] struct {
+	Value    T
+	Err      error
+	Attempts int
+}
+
+// errSkip - значение ушло в dead letters, ParallelMap просто идёт дальше
+var errSkip = errors.New("item skipped")
+
+// WithDeadLetter отдаёт значения, на которых fn вернула ошибку, в dead вместо того,
+// чтобы останавливать звено. dead вызывается из разных горутин.
+// Обычно fn - это Retry(...), тогда Attempts - сколько было попыток.
+func WithDeadLetter[In, Out any](fn func(ctx context.Context, val In) (Out, error), dead func(DeadLetter[In])) func(ctx context.Context, val In) (Out, error) {
+	return func(ctx context.Context, val In) (Out, error) {
+		res, err := fn(ctx, val)
+		if err == nil || ctx.Err() != nil {
+			return res, err
+		}
+		letter := DeadLetter[In]{Value: val, Err: err, Attempts: 1}
+		var retryErr *RetryError
+		if errors.As(err, &retryErr) {
+			letter.Attempts = retryErr.Attempts
+		}
+		dead(letter)
+		return res, errSkip
+	}
+}
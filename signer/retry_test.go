@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryBackoff(t *testing.T) {
+	clock := NewVirtualClock(virtualStart)
+	// спит только сам Do между попытками
+	stop := clock.AutoAdvance(1)
+	defer stop()
+
+	errFlaky := errors.New("flaky")
+	calls := 0
+	p := RetryPolicy{Attempts: 4, Backoff: 100 * time.Millisecond, Clock: clock}
+	err := p.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		if calls < 3 {
+			return errFlaky
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Fatalf("expected success on 3rd attempt, got %v after %d calls", err, calls)
+	}
+	// паузы 100мс и 200мс
+	if end := clock.Now().Sub(virtualStart); end != 300*time.Millisecond {
+		t.Errorf("bad backoff: %s", end)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), func(ctx context.Context) error {
+		calls++
+		return errFlaky
+	})
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || retryErr.Attempts != 4 || !errors.Is(err, errFlaky) || calls != 4 {
+		t.Errorf("expected RetryError after 4 attempts, got %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryBackoffLimits(t *testing.T) {
+	p := RetryPolicy{Backoff: time.Second, MaxBackoff: 5 * time.Second, Jitter: 0.5}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt + 1); d > max || d < max/2 {
+				t.Errorf("attempt %d: backoff %s out of [%s, %s]", attempt+1, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestRetryTimeout(t *testing.T) {
+	clock := NewVirtualClock(virtualStart)
+	hung := make(chan struct{})
+	// таймаут первой попытки истекает, когда она точно повисла, вторая отвечает сразу
+	go func() {
+		clock.BlockUntil(1)
+		<-hung
+		clock.Advance(time.Second)
+	}()
+
+	var calls int32
+	fn := Retry(RetryPolicy{Attempts: 2, Timeout: time.Second, Clock: clock}, func(ctx context.Context, val int) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// первый вызов виснет, пока его не бросят
+			close(hung)
+			<-ctx.Done()
+			return 0, ctx.Err()
+		}
+		return val * 2, nil
+	})
+	res, err := fn(context.Background(), 21)
+	if err != nil || res != 42 {
+		t.Fatalf("expected 42 on second attempt, got %v, %v", res, err)
+	}
+	if end := clock.Now().Sub(virtualStart); end != time.Second {
+		t.Errorf("hanging attempt must be dropped after timeout, got %s", end)
+	}
+
+	// таймер второй попытки так и висит, поэтому часы новые
+	clock = NewVirtualClock(virtualStart)
+	go func() {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+	}()
+	fn = Retry(RetryPolicy{Timeout: time.Second, Clock: clock}, func(ctx context.Context, val int) (int, error) {
+		<-ctx.Done()
+		return 0, ctx.Err()
+	})
+	if _, err := fn(context.Background(), 1); !errors.Is(err, ErrAttemptTimeout) {
+		t.Errorf("expected ErrAttemptTimeout, got %v", err)
+	}
+}
+
+func TestRetryTimeoutLateResult(t *testing.T) {
+	clock := NewVirtualClock(virtualStart)
+	hung := make(chan struct{})
+	go func() {
+		clock.BlockUntil(1)
+		<-hung
+		clock.Advance(time.Second)
+	}()
+
+	// брошенная попытка досчитывает, пока идёт следующая, и её ответ не должен ничего задеть
+	release := make(chan struct{})
+	late := make(chan struct{})
+	var calls int32
+	fn := Retry(RetryPolicy{Attempts: 2, Timeout: time.Second, Clock: clock}, func(ctx context.Context, val int) (int, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			defer close(late)
+			close(hung)
+			<-release
+			return 1, nil
+		}
+		close(release)
+		return val * 2, nil
+	})
+	res, err := fn(context.Background(), 21)
+	<-late
+	if err != nil || res != 42 {
+		t.Fatalf("expected 42 from second attempt, got %v, %v", res, err)
+	}
+}
+
+func TestRetryDeadLetter(t *testing.T) {
+	errOdd := errors.New("odd")
+	var (
+		mu   sync.Mutex
+		dead []DeadLetter[int]
+	)
+	fn := WithDeadLetter(Retry(RetryPolicy{Attempts: 3}, func(ctx context.Context, val int) (int, error) {
+		if val%2 == 1 {
+			return 0, errOdd
+		}
+		return val * 10, nil
+	}), func(letter DeadLetter[int]) {
+		mu.Lock()
+		dead = append(dead, letter)
+		mu.Unlock()
+	})
+
+	res, err := Then(FromSlice(1, 2, 3, 4), ParallelMap(4, fn, true)).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(res, []int{20, 40}) {
+		t.Errorf("bad result: %v", res)
+	}
+	sort.Slice(dead, func(i, j int) bool { return dead[i].Value < dead[j].Value })
+	if len(dead) != 2 || dead[0].Value != 1 || dead[1].Value != 3 {
+		t.Fatalf("bad dead letters: %+v", dead)
+	}
+	for _, letter := range dead {
+		if letter.Attempts != 3 || !errors.Is(letter.Err, errOdd) {
+			t.Errorf("bad dead letter: %+v", letter)
+		}
+	}
+}
+
+func TestSignerRetry(t *testing.T) {
+	clean := newFastSigner("")
+	flaky := newFastSigner("")
+	hang := make(chan struct{})
+	defer close(hang)
+	var hung int32
+	flaky.Crc32 = func(data string) string {
+		switch {
+		case data == "3" && atomic.CompareAndSwapInt32(&hung, 0, 1):
+			// подвисший вызов к сервису подписи
+			<-hang
+		case data == "5":
+			panic("service is down")
+		}
+		return clean.Crc32(data)
+	}
+
+	var (
+		mu   sync.Mutex
+		dead []DeadLetter[string]
+	)
+	flaky.Retry = RetryPolicy{Attempts: 3, Timeout: 50 * time.Millisecond, Backoff: time.Millisecond}
+	flaky.DeadLetters = func(letter DeadLetter[string]) {
+		mu.Lock()
+		dead = append(dead, letter)
+		mu.Unlock()
+	}
+
+	res, err := flaky.Sign(context.Background(), "1", "3", "5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := expectedSign(clean, "1", "3")
+	sort.Strings(expected)
+	if res != strings.Join(expected, "_") {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", res, strings.Join(expected, "_"))
+	}
+
+	var p *PanicError
+	if len(dead) != 1 || dead[0].Value != "5" || dead[0].Attempts != 3 || !errors.As(dead[0].Err, &p) {
+		t.Errorf("bad dead letters: %+v", dead)
+	}
+}
@@ -1,13 +1,40 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"sort"
-	"sync"
+	"strconv"
+	"strings"
 )
 
 var in, out chan interface{}
 
+// signFuncs - функции расчёта, которыми пользуются звенья
+type signFuncs struct {
+	md5   func(data string) string
+	crc32 func(data string) string
+	// md5 одновременно считать нельзя - будет перегрев. Подменённый в тестах OverheatLock
+	// при столкновении спит секунду, поэтому глобальные функции сначала встают в очередь
+	// defaultOverheat, её же показывает defaultSigner.Md5Stats()
+	md5Queue *Md5Guard
+	// rounds - сколько хешей считает MultiHash, singleSep - разделитель двух половин SingleHash
+	rounds    int
+	singleSep string
+	// retry - таймаут и повторы каждого вызова crc32, dead - куда уходят значения,
+	// для которых он так и не посчитался. У глобальных функций их нет
+	retry RetryPolicy
+	dead  func(DeadLetter[string])
+}
+
+// globalSignFuncs идут через переменные из common.go, чтобы их можно было подменить
+var globalSignFuncs = signFuncs{
+	md5:       func(data string) string { return DataSignerMd5(data) },
+	crc32:     func(data string) string { return DataSignerCrc32(data) },
+	md5Queue:  defaultOverheat,
+	rounds:    6,
+	singleSep: "~",
+}
+
 // // Рабочая, но медленная
 // func SingleHash(in, out chan interface{}) {
 // 	hash1Ch := make(chan string)
@@ -25,34 +52,88 @@ var in, out chan interface{}
 // }
 
 func SingleHash(in, out chan interface{}) {
-	hash1Slice := make([]chan string, 0)
-	hash2Slice := make([]chan string, 0)
-	dataSlice := make([]string, 0)
-
-	index := 0
-	for i := range in {
-		data := fmt.Sprintf("%v", i)
-		hash1Slice = append(hash1Slice, make(chan string))
-		hash2Slice = append(hash2Slice, make(chan string))
-		dataSlice = append(dataSlice, data)
-		index++
+	runStringStage(SingleHashStage, in, out)
+}
+
+// SingleHashStage начинает считать каждое значение сразу, как оно пришло.
+// md5 считается строго по одному, crc32 - параллельно, порядок на выходе совпадает со входом.
+var SingleHashStage = globalSignFuncs.singleHashStage()
+
+func (f signFuncs) singleHashStage() Stage[string, string] {
+	return ParallelMap(reorderWindow, f.withDeadLetter(f.singleHash), true)
+}
+
+// indexed - значение вместе с его номером и исходными данными. Так результат
+// не теряет, к какому входу относится, даже если часть значений выпала по дороге
+// (DeadLetters, Overflow с выбрасыванием, PanicSkip)
+type indexed struct {
+	index int
+	input string
+	value string
+}
+
+// indexedStage - звено как singleHashStage/multiHashStage, но над indexed
+func (f signFuncs) indexedStage(fn func(ctx context.Context, data string) (string, error)) Stage[indexed, indexed] {
+	return ParallelMap(reorderWindow, func(ctx context.Context, it indexed) (indexed, error) {
+		res, err := fn(ctx, it.value)
+		return indexed{index: it.index, input: it.input, value: res}, err
+	}, true)
+}
+
+func (f signFuncs) withDeadLetter(fn func(ctx context.Context, data string) (string, error)) func(ctx context.Context, data string) (string, error) {
+	if f.dead == nil {
+		return fn
 	}
+	return WithDeadLetter(fn, f.dead)
+}
 
-	for i := 0; i < index; i++ {
-		go func(data string, index int) {
-			hash1 := DataSignerCrc32(data)
-			hash1Slice[index] <- fmt.Sprintf("%v", hash1)
-		}(dataSlice[i], i)
-		md5 := DataSignerMd5(dataSlice[i])
-		go func(md5 string, index int) {
-			hash2 := DataSignerCrc32(md5)
-			hash2Slice[index] <- fmt.Sprintf("%v", hash2)
-		}(md5, i)
+// callCrc32 считает crc32 с таймаутом и повторами, если они заданы
+func (f signFuncs) callCrc32(ctx context.Context, data string) (string, error) {
+	if !f.retry.enabled() {
+		return f.crc32(data), nil
 	}
+	return Retry(f.retry, func(ctx context.Context, data string) (string, error) {
+		return f.crc32(data), nil
+	})(ctx, data)
+}
 
-	for i := 0; i < index; i++ {
-		out <- fmt.Sprintf("%v~%v", <-hash1Slice[i], <-hash2Slice[i])
+func (f signFuncs) lockedMd5(data string) string {
+	if f.md5Queue != nil {
+		f.md5Queue.Lock()
+		defer f.md5Queue.Unlock()
 	}
+	return f.md5(data)
+}
+
+func (f signFuncs) singleHash(ctx context.Context, data string) (string, error) {
+	type result struct {
+		hash string
+		err  error
+	}
+	hash1 := make(chan result, 1)
+	go func() {
+		// паника здесь уронила бы весь процесс, а не одно значение
+		var hash string
+		err := recoverRun(func() (err error) {
+			hash, err = f.callCrc32(ctx, data)
+			return err
+		})
+		hash1 <- result{hash, err}
+	}()
+	// и здесь паника не должна выйти раньше, чем закончится горутина выше
+	var hash2 string
+	err := recoverRun(func() (err error) {
+		hash2, err = f.callCrc32(ctx, f.lockedMd5(data))
+		return err
+	})
+	res := <-hash1
+	if res.err != nil {
+		return "", res.err
+	}
+	if err != nil {
+		return "", err
+	}
+	return res.hash + f.singleSep + hash2, nil
 }
 
 // // Рабочая, но медленная
@@ -76,76 +157,99 @@ func SingleHash(in, out chan interface{}) {
 // }
 
 func MultiHash(in, out chan interface{}) {
-	hashSlice := make([]chan string, 0)
-	dataSlice := make([]string, 0)
-
-	index := 0
-	for i := range in {
-		data := fmt.Sprintf("%v", i)
-		dataSlice = append(dataSlice, data)
-		hashSlice = append(hashSlice, make(chan string))
-		index++
-	}
+	runStringStage(MultiHashStage, in, out)
+}
 
-	for i := 0; i < index; i++ {
-		go func(index int) {
-			data := dataSlice[index]
-			res := ""
-			var resArr [6]chan string
-			for i := 0; i < 6; i++ {
-				resArr[i] = make(chan string)
-				go func(data string, i int, resArr [6]chan string) {
-					resArr[i] <- DataSignerCrc32(fmt.Sprintf("%v%v", i, data))
-				}(data, i, resArr)
-			}
+var MultiHashStage = globalSignFuncs.multiHashStage()
 
-			for i := 0; i < 6; i++ {
-				res += <-resArr[i]
-			}
-			hashSlice[index] <- res
-		}(i)
-	}
+func (f signFuncs) multiHashStage() Stage[string, string] {
+	return ParallelMap(reorderWindow, f.withDeadLetter(f.multiHash), true)
+}
 
-	for i := 0; i < index; i++ {
-		out <- (<-hashSlice[i])
+func (f signFuncs) multiHash(ctx context.Context, data string) (string, error) {
+	steps := make([]int, f.rounds)
+	for i := range steps {
+		steps[i] = i
 	}
+	hashes, err := MapSlice(ctx, len(steps), steps, func(ctx context.Context, th int) (string, error) {
+		return f.callCrc32(ctx, strconv.Itoa(th)+data)
+	})
+	return strings.Join(hashes, ""), err
 }
 
+// сколько значений может одновременно считаться в одном звене
+const reorderWindow = MaxInputDataLen
+
 func CombineResults(in, out chan interface{}) {
-	res := ""
-	resArr := make([]string, 0)
-	for i := range in {
-		resArr = append(resArr, fmt.Sprintf("%v", i))
-	}
+	runStringStage(CombineResultsStage, in, out)
+}
 
-	sort.Strings(resArr)
+func CombineResultsStage(ctx context.Context, in <-chan string, out chan<- string) error {
+	return combineResultsStage("_")(ctx, in, out)
+}
 
-	for _, data := range resArr {
-		if res != "" {
-			res += "_" + data
-		} else {
-			res += data
+func combineResultsStage(sep string) Stage[string, string] {
+	return func(ctx context.Context, in <-chan string, out chan<- string) error {
+		resArr := make([]string, 0)
+		for data := range in {
+			resArr = append(resArr, data)
 		}
+
+		if !emit(ctx, out, combine(sep, resArr)) {
+			return ctx.Err()
+		}
+		return nil
 	}
-	out <- res
 }
 
-func ExecutePipeline(jobs ...job) {
-	var wg sync.WaitGroup
-	in := make(chan interface{}, 100)
-	var out chan interface{}
+// runStringStage запускает типизированное звено внутри обычного job:
+// значения на входе приводятся к строке, как раньше через fmt.Sprintf("%v").
+// Вернуть ошибку звена job не может, поэтому она уходит паникой stageFailure,
+// которую recoverRun снаружи превращает обратно в ошибку
+func runStringStage(s Stage[string, string], in, out chan interface{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	typedIn := make(chan string, 100)
+	typedOut := make(chan string, 100)
+	go func() {
+		defer close(typedIn)
+		for val := range in {
+			select {
+			case typedIn <- fmt.Sprintf("%v", val):
+			case <-ctx.Done():
+				// звено закончило, не дочитав вход - вычитываем, чтобы не держать предыдущее
+				drain(in)
+				return
+			}
+		}
+	}()
+	errc := make(chan error, 1)
+	go func() {
+		defer close(typedOut)
+		errc <- recoverRun(func() error { return s(ctx, typedIn, typedOut) })
+	}()
+	for val := range typedOut {
+		out <- val
+	}
+	cancel()
+	if err := <-errc; err != nil {
+		panic(stageFailure{err})
+	}
+}
 
+// ExecutePipeline возвращает первую ошибку звена. У обычных job её неоткуда взять,
+// кроме SingleHash, MultiHash, CombineResults и MerkleCombine - они сообщают об ошибке
+// и панике своего звена через stageFailure
+func ExecutePipeline(jobs ...job) error {
+	stages := make([]stage, 0, len(jobs))
 	for _, j := range jobs {
-		out = make(chan interface{}, 100)
-		wg.Add(1)
-		go func(j job, in, out chan interface{}) {
-			defer wg.Done()
+		j := j
+		stages = append(stages, stage{name: funcName(j), run: func(ctx context.Context, in, out chan interface{}) error {
 			j(in, out)
-			close(out)
-		}(j, in, out)
-		in = out
+			return nil
+		}})
 	}
-	wg.Wait()
+	return executeStages(context.Background(), PipelineConfig{}, stages)
 }
 
 // func main() {
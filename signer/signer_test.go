@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"fmt"
+	"hash/crc32"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// fastSigners подменяет функции расчёта на такие же, но без задержек
+func fastSigners(t *testing.T) {
+	origMd5, origCrc32 := DataSignerMd5, DataSignerCrc32
+	DataSignerMd5 = func(data string) string {
+		return fmt.Sprintf("%x", md5.Sum([]byte(data)))
+	}
+	DataSignerCrc32 = func(data string) string {
+		return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(data))), 10)
+	}
+	t.Cleanup(func() {
+		DataSignerMd5, DataSignerCrc32 = origMd5, origCrc32
+	})
+}
+
+func TestSingleHashStreaming(t *testing.T) {
+	fastSigners(t)
+	in := make(chan string)
+	out := make(chan string)
+	go SingleHashStage(context.Background(), in, out)
+	defer close(in)
+
+	// вход не закрыт, а результат уже должен прийти
+	in <- "0"
+	select {
+	case res := <-out:
+		if res != "4108050209~502633748" {
+			t.Errorf("bad SingleHash result: %v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("SingleHash accumulates values instead of streaming them")
+	}
+}
+
+func TestMultiHashStreaming(t *testing.T) {
+	fastSigners(t)
+	in := make(chan string)
+	out := make(chan string)
+	go MultiHashStage(context.Background(), in, out)
+	defer close(in)
+
+	in <- "4108050209~502633748"
+	select {
+	case res := <-out:
+		if res != "29568666068035183841425683795340791879727309630931025356555" {
+			t.Errorf("bad MultiHash result: %v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("MultiHash accumulates values instead of streaming them")
+	}
+}
+
+func TestHashStagesKeepOrder(t *testing.T) {
+	fastSigners(t)
+	// значений заметно больше окна, чтобы очередь успела заполниться
+	const count = reorderWindow * 5
+	source := Source(func(ctx context.Context, out chan<- string) error {
+		for i := 0; i < count; i++ {
+			emit(ctx, out, strconv.Itoa(i))
+		}
+		return nil
+	})
+	res, err := Then(Then(source, SingleHashStage), MultiHashStage).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != count {
+		t.Fatalf("expected %v results, got %v", count, len(res))
+	}
+	for i, hash := range res {
+		data := strconv.Itoa(i)
+		single := DataSignerCrc32(data) + "~" + DataSignerCrc32(DataSignerMd5(data))
+		multi := ""
+		for th := 0; th < 6; th++ {
+			multi += DataSignerCrc32(strconv.Itoa(th) + single)
+		}
+		if hash != multi {
+			t.Fatalf("result %v out of order: got %v, expected %v", i, hash, multi)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import "context"
+
+// Stage - типизированное звено: типы входа и выхода проверяются при компиляции
+type Stage[In, Out any] func(ctx context.Context, in <-chan In, out chan<- Out) error
+
+// Chain - собираемый конвейер, на выходе которого значения типа T
+type Chain[T any] struct {
+	cfg    PipelineConfig
+	stages int
+	build  func(g *runGroup) chan T
+}
+
+// emit - типизированный send
+func emit[T any](ctx context.Context, out chan<- T, val T) bool {
+	select {
+	case out <- val:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Source начинает конвейер с функции, которая только пишет
+func Source[T any](fn func(ctx context.Context, out chan<- T) error) *Chain[T] {
+	name := funcName(fn)
+	return &Chain[T]{stages: 1, build: func(g *runGroup) chan T {
+		out, next := link[T](g, 0, name, 1)
+		g.goStage(0, name, func() error {
+			defer close(out)
+			return fn(g.ctx, out)
+		})
+		return next
+	}}
+}
+
+// Then добавляет звено в конец конвейера
+func Then[In, Out any](c *Chain[In], s Stage[In, Out]) *Chain[Out] {
+	name := funcName(s)
+	index := c.stages
+	return &Chain[Out]{cfg: c.cfg, stages: index + 1, build: func(g *runGroup) chan Out {
+		in := c.build(g)
+		out, next := link[Out](g, index, name, index+1)
+		run := isolate(g, index, name, in, func(ctx context.Context, in chan In) error {
+			return s(ctx, in, out)
+		})
+		g.goStage(index, name, func() error {
+			defer close(out)
+			return run()
+		})
+		return next
+	}}
+}
+
+// With возвращает тот же конвейер с настройками запуска
+func (c *Chain[T]) With(cfg PipelineConfig) *Chain[T] {
+	res := *c
+	res.cfg = cfg
+	return &res
+}
+
+// Run запускает конвейер и отдаёт каждое значение с выхода в sink.
+// Ошибка sink останавливает конвейер так же, как ошибка звена.
+func (c *Chain[T]) Run(ctx context.Context, sink func(val T) error) error {
+	g := newRunGroup(ctx, c.cfg)
+	out := c.build(g)
+	g.goStage(c.stages, "sink", func() error {
+		for val := range out {
+			if err := sink(val); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return g.wait()
+}
+
+// Collect запускает конвейер и собирает все значения с выхода
+func (c *Chain[T]) Collect(ctx context.Context) ([]T, error) {
+	var res []T
+	err := c.Run(ctx, func(val T) error {
+		res = append(res, val)
+		return nil
+	})
+	return res, err
+}
+
+// FromSlice - источник из готовых значений
+func FromSlice[T any](vals ...T) *Chain[T] {
+	return Source(func(ctx context.Context, out chan<- T) error {
+		for _, val := range vals {
+			if !emit(ctx, out, val) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+}
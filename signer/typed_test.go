@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestTypedSigner(t *testing.T) {
+	testExpected := "29568666068035183841425683795340791879727309630931025356555_4958044192186797981418233587017209679042592862002427381542"
+
+	source := Source(func(ctx context.Context, out chan<- int) error {
+		for _, num := range []int{0, 1} {
+			emit(ctx, out, num)
+		}
+		return nil
+	})
+	toString := func(ctx context.Context, in <-chan int, out chan<- string) error {
+		for num := range in {
+			emit(ctx, out, strconv.Itoa(num))
+		}
+		return nil
+	}
+	chain := Then(Then(Then(Then(source, toString), SingleHashStage), MultiHashStage), CombineResultsStage)
+
+	res, err := chain.Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(res) != 1 || res[0] != testExpected {
+		t.Errorf("results not match\nGot: %v\nExpected: %v", res, testExpected)
+	}
+}
+
+func TestTypedStageError(t *testing.T) {
+	errOdd := errors.New("odd")
+	double := func(ctx context.Context, in <-chan int, out chan<- int) error {
+		for num := range in {
+			if num%2 == 1 {
+				return errOdd
+			}
+			emit(ctx, out, num*2)
+		}
+		return nil
+	}
+
+	_, err := Then(FromSlice(2, 4, 5, 6), double).Collect(context.Background())
+	var stageErr *StageError
+	if !errors.As(err, &stageErr) || stageErr.Index != 1 || !errors.Is(err, errOdd) {
+		t.Errorf("expected error from stage 1, got %v", err)
+	}
+
+	errStop := errors.New("stop")
+	got := 0
+	err = FromSlice(1, 2, 3).Run(context.Background(), func(val int) error {
+		got += val
+		if val == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || got != 3 {
+		t.Errorf("sink error must stop pipeline, got %v, sum %v", err, got)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Mismatch - значение, MultiHash которого не нашёлся в подписи
+type Mismatch struct {
+	Index    int
+	Input    string
+	Computed string
+}
+
+// VerifyError описывает, чем подпись расходится с данными
+type VerifyError struct {
+	// Mismatches - значения, чьих компонентов нет в подписи (значение изменено или добавлено)
+	Mismatches []Mismatch
+	// Extra - компоненты подписи, которым не нашлось значения (значение изменено или потеряно)
+	Extra []string
+}
+
+func (e *VerifyError) Error() string {
+	parts := make([]string, 0, len(e.Mismatches)+1)
+	for _, m := range e.Mismatches {
+		parts = append(parts, fmt.Sprintf("input %d (%q): multihash %s not in signature", m.Index, m.Input, m.Computed))
+	}
+	if len(e.Extra) > 0 {
+		parts = append(parts, fmt.Sprintf("%d signature components without input", len(e.Extra)))
+	}
+	return "signature mismatch: " + strings.Join(parts, "; ")
+}
+
+// Verifier сверяет MultiHash значений с подписью по мере их поступления
+type Verifier struct {
+	signer *Signer
+	// сколько раз каждый компонент ещё может встретиться - одинаковые значения дают одинаковые компоненты
+	expected map[string]int
+	mismatch []Mismatch
+}
+
+func (s *Signer) NewVerifier(signature string) *Verifier {
+	expected := map[string]int{}
+	if signature != "" {
+		for _, part := range strings.Split(signature, s.CombineSep) {
+			expected[part]++
+		}
+	}
+	return &Verifier{signer: s, expected: expected}
+}
+
+// Stage считает MultiHash каждого значения и сверяет с подписью. На выход уходят
+// только расхождения, сразу как они найдены - результат проверки целиком даёт Err после конца входа.
+func (v *Verifier) Stage() Stage[string, Mismatch] {
+	f := v.signer.funcs()
+	single := f.indexedStage(f.withDeadLetter(f.singleHash))
+	multi := f.indexedStage(f.withDeadLetter(f.multiHash))
+	return func(ctx context.Context, in <-chan string, out chan<- Mismatch) error {
+		// номер и исходное значение идут вместе с хешем - выпавшие по дороге значения не сбивают отчёт
+		hashes := Then(Then(Source(func(ctx context.Context, hashIn chan<- indexed) error {
+			index := 0
+			for data := range in {
+				if !emit(ctx, hashIn, indexed{index: index, input: data, value: data}) {
+					return ctx.Err()
+				}
+				index++
+			}
+			return nil
+		}), single), multi)
+
+		return hashes.Run(ctx, func(it indexed) error {
+			if m, ok := v.check(it); !ok && !emit(ctx, out, m) {
+				return ctx.Err()
+			}
+			return nil
+		})
+	}
+}
+
+func (v *Verifier) check(it indexed) (Mismatch, bool) {
+	if v.expected[it.value] > 0 {
+		v.expected[it.value]--
+		return Mismatch{}, true
+	}
+	m := Mismatch{Index: it.index, Input: it.input, Computed: it.value}
+	v.mismatch = append(v.mismatch, m)
+	return m, false
+}
+
+// Err возвращает *VerifyError, если подпись не сошлась, вызывать после того, как вход закончился
+func (v *Verifier) Err() error {
+	var extra []string
+	for part, count := range v.expected {
+		for i := 0; i < count; i++ {
+			extra = append(extra, part)
+		}
+	}
+	if len(v.mismatch) == 0 && len(extra) == 0 {
+		return nil
+	}
+	return &VerifyError{Mismatches: v.mismatch, Extra: extra}
+}
+
+// VerifyStream проверяет подпись для значений из канала
+func (s *Signer) VerifyStream(ctx context.Context, inputs <-chan string, signature string) error {
+	v := s.NewVerifier(signature)
+	chain := Then(Source(func(ctx context.Context, out chan<- string) error {
+		for data := range inputs {
+			if !emit(ctx, out, data) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}), v.Stage())
+	if err := chain.With(s.Pipeline).Run(ctx, func(Mismatch) error { return nil }); err != nil {
+		return err
+	}
+	return v.Err()
+}
+
+// Verify проверяет, что signature - подпись inputs. При расхождении возвращает false
+// и *VerifyError с тем, какие значения не сошлись; другие ошибки - сбой самой проверки.
+func (s *Signer) Verify(inputs []string, signature string) (bool, error) {
+	in := make(chan string, len(inputs))
+	for _, data := range inputs {
+		in <- data
+	}
+	close(in)
+	err := s.VerifyStream(context.Background(), in, signature)
+	return err == nil, err
+}
+
+// Verify проверяет подпись Signer по умолчанию
+func Verify(inputs []string, signature string) (bool, error) {
+	return defaultSigner.Verify(inputs, signature)
+}
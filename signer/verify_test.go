@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	s := newFastSigner("")
+	inputs := []string{"0", "1", "1", "2", "3", "5", "8"}
+	signature, err := s.Sign(context.Background(), inputs...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ok, err := s.Verify(inputs, signature)
+	if !ok || err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+
+	// одно значение подменено
+	tampered := append([]string{}, inputs...)
+	tampered[3] = "42"
+	ok, err = s.Verify(tampered, signature)
+	var verifyErr *VerifyError
+	if ok || !errors.As(err, &verifyErr) {
+		t.Fatalf("tampered input accepted: %v", err)
+	}
+	if len(verifyErr.Mismatches) != 1 || verifyErr.Mismatches[0].Index != 3 || verifyErr.Mismatches[0].Input != "42" {
+		t.Errorf("bad mismatch report: %+v", verifyErr)
+	}
+	if len(verifyErr.Extra) != 1 {
+		t.Errorf("component of original value must be reported as extra: %+v", verifyErr)
+	}
+
+	// одно из повторяющихся значений потеряно
+	ok, err = s.Verify([]string{"0", "1", "2", "3", "5", "8"}, signature)
+	if ok || !errors.As(err, &verifyErr) || len(verifyErr.Mismatches) != 0 || len(verifyErr.Extra) != 1 {
+		t.Errorf("missing input not reported: %v", err)
+	}
+
+	// подпись другим ключом не подходит
+	other := newFastSigner("other salt")
+	if ok, _ := other.Verify(inputs, signature); ok {
+		t.Errorf("signature of other signer accepted")
+	}
+}
+
+func TestVerifierStage(t *testing.T) {
+	s := newFastSigner("")
+	signature, _ := s.Sign(context.Background(), "a", "b", "c")
+
+	v := s.NewVerifier(signature)
+	mismatches, err := Then(FromSlice("a", "x", "c", "y"), v.Stage()).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(mismatches) != 2 || mismatches[0].Index != 1 || mismatches[1].Index != 3 {
+		t.Errorf("bad mismatches: %+v", mismatches)
+	}
+	if v.Err() == nil {
+		t.Errorf("expected verify error")
+	}
+}
+
+func TestVerifierStageDeadLetters(t *testing.T) {
+	s := newFastSigner("")
+	signature, _ := s.Sign(context.Background(), "a", "b", "c")
+
+	crc32 := s.Crc32
+	s.Crc32 = func(data string) string {
+		if data == "broken" {
+			panic("service is down")
+		}
+		return crc32(data)
+	}
+	s.Retry = RetryPolicy{Attempts: 2}
+	var dead []string
+	s.DeadLetters = func(letter DeadLetter[string]) { dead = append(dead, letter.Value) }
+
+	// значение, ушедшее в DeadLetters, не сдвигает номера и входы следующих
+	v := s.NewVerifier(signature)
+	mismatches, err := Then(FromSlice("a", "broken", "x", "c"), v.Stage()).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(dead) != 1 || dead[0] != "broken" {
+		t.Errorf("bad dead letters: %v", dead)
+	}
+	if len(mismatches) != 1 || mismatches[0].Index != 2 || mismatches[0].Input != "x" {
+		t.Errorf("bad mismatches: %+v", mismatches)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Batch - звено, которое собирает значения в пачки по size штук. При every > 0 пачка
+// уходит не позже, чем через every после своего первого значения, даже неполная.
+// Остаток отдаётся, когда кончается вход. clock == nil - обычное время.
+// Нужен хотя бы один из size и every, иначе звено сразу возвращает ошибку.
+func Batch[T any](size int, every time.Duration, clock Clock) Stage[T, []T] {
+	if size <= 0 && every <= 0 {
+		return invalidStage[T, []T](fmt.Errorf("batch: size %d or every %v must be positive", size, every))
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- []T) error {
+		clock := clockOrSystem(clock)
+		var (
+			batch []T
+			timer <-chan time.Time
+		)
+		flush := func() bool {
+			if len(batch) == 0 {
+				return true
+			}
+			res := batch
+			batch, timer = nil, nil
+			return emit(ctx, out, res)
+		}
+
+		for {
+			select {
+			case val, ok := <-in:
+				if !ok {
+					if !flush() {
+						return ctx.Err()
+					}
+					return nil
+				}
+				batch = append(batch, val)
+				if len(batch) == 1 && every > 0 {
+					timer = clock.After(every)
+				}
+				if size > 0 && len(batch) >= size && !flush() {
+					return ctx.Err()
+				}
+			case <-timer:
+				if !flush() {
+					return ctx.Err()
+				}
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// Window - значения, пришедшие в [Start, End)
+type Window[T any] struct {
+	Start time.Time
+	End   time.Time
+	Items []T
+}
+
+// TumblingWindow - звено, которое режет поток на окна по size без перекрытий
+func TumblingWindow[T any](size time.Duration, clock Clock) Stage[T, Window[T]] {
+	return SlidingWindow[T](size, size, clock)
+}
+
+// SlidingWindow - звено, которое каждые step отдаёт окно из значений за последние size.
+// Время значения - когда оно пришло, границы окон кратны step. Окно уходит, как только
+// закончилось, пустые окна не отдаются. Когда вход кончается, оставшиеся окна
+// со значениями отдаются сразу, не дожидаясь их конца.
+// Неположительные size или step - звено сразу возвращает ошибку.
+func SlidingWindow[T any](size, step time.Duration, clock Clock) Stage[T, Window[T]] {
+	if size <= 0 || step <= 0 {
+		return invalidStage[T, Window[T]](fmt.Errorf("window: size %v and step %v must be positive", size, step))
+	}
+	type item struct {
+		at  time.Time
+		val T
+	}
+	return func(ctx context.Context, in <-chan T, out chan<- Window[T]) error {
+		clock := clockOrSystem(clock)
+		var items []item
+		// next - конец следующего окна
+		next := clock.Now().Truncate(step).Add(step)
+		timer := clock.After(next.Sub(clock.Now()))
+
+		emitWindow := func() bool {
+			w := Window[T]{Start: next.Add(-size), End: next}
+			for _, it := range items {
+				if !it.at.Before(w.Start) && it.at.Before(w.End) {
+					w.Items = append(w.Items, it.val)
+				}
+			}
+			// в следующие окна не попадёт то, что раньше их начала
+			next = next.Add(step)
+			keep := items[:0]
+			for _, it := range items {
+				if !it.at.Before(next.Add(-size)) {
+					keep = append(keep, it)
+				}
+			}
+			items = keep
+			return len(w.Items) == 0 || emit(ctx, out, w)
+		}
+
+		for {
+			select {
+			case val, ok := <-in:
+				if !ok {
+					for len(items) > 0 {
+						if !emitWindow() {
+							return ctx.Err()
+						}
+					}
+					return nil
+				}
+				items = append(items, item{at: clock.Now(), val: val})
+			case <-timer:
+				now := clock.Now()
+				for !next.After(now) {
+					if !emitWindow() {
+						return ctx.Err()
+					}
+				}
+				timer = clock.After(next.Sub(now))
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// invalidStage - звено с неверными параметрами, оно только останавливает конвейер
+func invalidStage[In, Out any](err error) Stage[In, Out] {
+	return func(ctx context.Context, in <-chan In, out chan<- Out) error {
+		return err
+	}
+}
+
+func combine(sep string, hashes []string) string {
+	sorted := append([]string{}, hashes...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, sep)
+}
+
+// CombineBatchStage - CombineResults для каждой пачки от Batch
+func (s *Signer) CombineBatchStage() Stage[[]string, string] {
+	return func(ctx context.Context, in <-chan []string, out chan<- string) error {
+		for batch := range in {
+			if !emit(ctx, out, combine(s.CombineSep, batch)) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
+
+// CombineWindowStage - CombineResults для каждого окна, так на бесконечном потоке
+// подпись выходит за каждое окно
+func (s *Signer) CombineWindowStage() Stage[Window[string], string] {
+	return func(ctx context.Context, in <-chan Window[string], out chan<- string) error {
+		for w := range in {
+			if !emit(ctx, out, combine(s.CombineSep, w.Items)) {
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+}
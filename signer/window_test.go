@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stampClock считает вызовы Now: так видно, что звено окон уже отметило время
+// пришедшего значения и время можно двигать дальше
+type stampClock struct {
+	*VirtualClock
+	stamps int64
+}
+
+func (c *stampClock) Now() time.Time {
+	now := c.VirtualClock.Now()
+	atomic.AddInt64(&c.stamps, 1)
+	return now
+}
+
+// feedWindows подаёт windowInput в звено окон в заданные моменты виртуального времени
+// и собирает окна. У звена спит только таймер конца окна.
+func feedWindows(t *testing.T, newStage func(clock Clock) Stage[string, Window[string]]) []Window[string] {
+	t.Helper()
+	clock := &stampClock{VirtualClock: NewVirtualClock(virtualStart)}
+	in := make(chan string)
+	out := make(chan Window[string], len(windowOrder))
+	errc := make(chan error, 1)
+	go func() {
+		errc <- newStage(clock)(context.Background(), in, out)
+		close(out)
+	}()
+
+	clock.BlockUntil(1)
+	for _, d := range windowOrder {
+		clock.Advance(virtualStart.Add(d).Sub(clock.VirtualClock.Now()))
+		// если окно кончилось, звено отдаёт его и заводит таймер следующего
+		clock.BlockUntil(1)
+		stamps := atomic.LoadInt64(&clock.stamps)
+		in <- windowInput[d]
+		for atomic.LoadInt64(&clock.stamps) == stamps {
+			runtime.Gosched()
+		}
+	}
+	close(in)
+
+	var res []Window[string]
+	for w := range out {
+		res = append(res, w)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return res
+}
+
+func TestBatchSize(t *testing.T) {
+	res, err := Then(FromSlice(1, 2, 3, 4, 5, 6, 7), Batch[int](3, 0, nil)).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(res, [][]int{{1, 2, 3}, {4, 5, 6}, {7}}) {
+		t.Errorf("bad batches: %v", res)
+	}
+}
+
+func TestBatchEvery(t *testing.T) {
+	clock := NewVirtualClock(virtualStart)
+	in := make(chan string)
+	out := make(chan []string)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- Batch[string](10, 100*time.Millisecond, clock)(context.Background(), in, out)
+		close(out)
+	}()
+
+	in <- "a"
+	// первое значение пачки заводит таймер
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Millisecond)
+	in <- "b"
+	// первая пачка уходит по таймеру через 100мс после "a"
+	clock.Advance(90 * time.Millisecond)
+	if batch := <-out; !reflect.DeepEqual(batch, []string{"a", "b"}) {
+		t.Errorf("bad batch by timer: %v", batch)
+	}
+	clock.Advance(50 * time.Millisecond)
+	in <- "c"
+	clock.BlockUntil(1)
+	clock.Advance(10 * time.Millisecond)
+	in <- "d"
+	// вторая - с концом входа, не дожидаясь таймера
+	close(in)
+	if batch := <-out; !reflect.DeepEqual(batch, []string{"c", "d"}) {
+		t.Errorf("bad batch at end of input: %v", batch)
+	}
+	if batch, ok := <-out; ok {
+		t.Errorf("unexpected batch: %v", batch)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+var windowInput = map[time.Duration]string{
+	100 * time.Millisecond:  "1",
+	500 * time.Millisecond:  "2",
+	1200 * time.Millisecond: "3",
+	2700 * time.Millisecond: "4",
+}
+
+var windowOrder = []time.Duration{100 * time.Millisecond, 500 * time.Millisecond, 1200 * time.Millisecond, 2700 * time.Millisecond}
+
+func checkWindows(t *testing.T, res []Window[string], expected [][]string, ends []time.Duration, size time.Duration) {
+	t.Helper()
+	if len(res) != len(expected) {
+		t.Fatalf("bad windows: %+v", res)
+	}
+	for i, w := range res {
+		end := virtualStart.Add(ends[i])
+		if !reflect.DeepEqual(w.Items, expected[i]) || !w.End.Equal(end) || !w.Start.Equal(end.Add(-size)) {
+			t.Errorf("window %d: got %v [%s, %s), expected %v ending at %s", i, w.Items, w.Start, w.End, expected[i], end)
+		}
+	}
+}
+
+func TestTumblingWindow(t *testing.T) {
+	res := feedWindows(t, func(clock Clock) Stage[string, Window[string]] {
+		return TumblingWindow[string](time.Second, clock)
+	})
+	checkWindows(t, res, [][]string{{"1", "2"}, {"3"}, {"4"}},
+		[]time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, time.Second)
+}
+
+func TestSlidingWindow(t *testing.T) {
+	res := feedWindows(t, func(clock Clock) Stage[string, Window[string]] {
+		return SlidingWindow[string](2*time.Second, time.Second, clock)
+	})
+	checkWindows(t, res, [][]string{{"1", "2"}, {"1", "2", "3"}, {"3", "4"}, {"4"}},
+		[]time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 4 * time.Second}, 2*time.Second)
+}
+
+func TestWindowInvalid(t *testing.T) {
+	for name, stage := range map[string]Stage[int, Window[int]]{
+		"tumbling 0":   TumblingWindow[int](0, nil),
+		"sliding step": SlidingWindow[int](time.Second, 0, nil),
+		"sliding size": SlidingWindow[int](-time.Second, time.Second, nil),
+	} {
+		if _, err := Then(FromSlice(1, 2), stage).Collect(context.Background()); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := Then(FromSlice(1, 2), Batch[int](0, 0, nil)).Collect(context.Background()); err == nil {
+		t.Errorf("batch without size and every: expected error")
+	}
+}
+
+func TestCombineWindowStage(t *testing.T) {
+	windows := feedWindows(t, func(clock Clock) Stage[string, Window[string]] {
+		return TumblingWindow[string](time.Second, clock)
+	})
+	s := newFastSigner("")
+	res, err := Then(FromSlice(windows...), s.CombineWindowStage()).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(res, []string{"1_2", "3", "4"}) {
+		t.Errorf("bad signatures per window: %v", res)
+	}
+
+	batches, err := Then(Then(FromSlice("b", "a", "d", "c"), Batch[string](2, 0, nil)), s.CombineBatchStage()).Collect(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(batches, []string{"a_b", "c_d"}) {
+		t.Errorf("bad signatures per batch: %v", batches)
+	}
+}
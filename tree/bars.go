@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+const barWidth = 20
+
+// sumSizes складывает размеры файлов под path и запоминает итог для каждого каталога.
+// Файлы учитываются и без -f, иначе размеры каталогов не имели бы смысла.
+func (w *walker) sumSizes(path string) int64 {
+	dir, err := w.readDir(path, true)
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for _, d := range dir {
+		finfo, err := d.Info()
+		if err != nil {
+			continue
+		}
+		if d.IsDir() {
+			if w.sameFS(finfo) {
+				total += w.sumSizes(path + "/" + d.Name())
+			}
+			continue
+		}
+		total += finfo.Size()
+	}
+	w.sizes[path] = total
+	return total
+}
+
+func drawBar(size, total int64) string {
+	share := 0.0
+	if total > 0 {
+		share = float64(size) / float64(total)
+	}
+	filled := int(share*barWidth + 0.5)
+	return fmt.Sprintf("[%s%s] %5.1f%%", strings.Repeat("#", filled), strings.Repeat(" ", barWidth-filled), share*100)
+}
+
+// sizeBar возвращает полоску с долей записи в родительском каталоге parent,
+// для каталогов заодно выводится их суммарный размер
+func (w *walker) sizeBar(parent, path string, finfo fs.FileInfo) string {
+	size := finfo.Size()
+	prefix := " "
+	if finfo.IsDir() {
+		size = w.sizes[path]
+		prefix = fmt.Sprintf(" (%vb) ", size)
+	}
+	return prefix + drawBar(size, w.sizes[parent])
+}
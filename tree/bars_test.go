@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDrawBar(t *testing.T) {
+	cases := []struct {
+		size, total int64
+		expected    string
+	}{
+		{0, 0, "[                    ]   0.0%"},
+		{1, 4, "[#####               ]  25.0%"},
+		{1, 3, "[#######             ]  33.3%"},
+		{4, 4, "[####################] 100.0%"},
+	}
+	for _, c := range cases {
+		if got := drawBar(c.size, c.total); got != c.expected {
+			t.Errorf("drawBar(%v, %v) = %q; expected %q", c.size, c.total, got, c.expected)
+		}
+	}
+}
+
+func TestTreeBars(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "logs"), 0755)
+	os.WriteFile(filepath.Join(root, "logs", "a.log"), bytes.Repeat([]byte("a"), 300), 0644)
+	os.WriteFile(filepath.Join(root, "logs", "b.log"), bytes.Repeat([]byte("b"), 100), 0644)
+	os.WriteFile(filepath.Join(root, "readme.md"), bytes.Repeat([]byte("r"), 100), 0644)
+
+	out := new(bytes.Buffer)
+	err := dirTreeOpts(out, root, options{bars: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected := "└───logs (400b) [################    ]  80.0%\n"
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	out.Reset()
+	err = dirTreeOpts(out, root, options{printFiles: true, bars: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected = `├───logs (400b) [################    ]  80.0%
+│	├───a.log (300b) [###############     ]  75.0%
+│	└───b.log (100b) [#####               ]  25.0%
+└───readme.md (100b) [####                ]  20.0%
+`
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
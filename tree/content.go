@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// http.DetectContentType смотрит не больше 512 байт
+const sniffSize = 512
+
+func isBinary(head []byte, mime string) bool {
+	return bytes.IndexByte(head, 0) >= 0 || !strings.HasPrefix(mime, "text/")
+}
+
+func countLines(r io.Reader) (int, error) {
+	buf := make([]byte, 32*1024)
+	count := 0
+	var last byte
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			count += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	// последняя строка без перевода строки тоже считается
+	if last != 0 && last != '\n' {
+		count++
+	}
+	return count, nil
+}
+
+// describeContent возвращает подписи для файла: число строк (только для текстовых) и mime-тип.
+// Если файл не читается - подписи просто не выводятся.
+func describeContent(path string, lines, mime bool) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+
+	var parts []string
+	if lines && !isBinary(head, contentType) {
+		count, err := countLines(io.MultiReader(bytes.NewReader(head), file))
+		if err == nil {
+			parts = append(parts, fmt.Sprintf("%v lines", count))
+		}
+	}
+	if mime {
+		parts = append(parts, contentType)
+	}
+	return parts
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCountLines(t *testing.T) {
+	cases := map[string]int{
+		"":            0,
+		"one":         1,
+		"one\n":       1,
+		"one\ntwo":    2,
+		"one\ntwo\n":  2,
+		"\n\n\n":      3,
+		"a\r\nb\r\nc": 3,
+	}
+	for in, expected := range cases {
+		got, err := countLines(strings.NewReader(in))
+		if err != nil || got != expected {
+			t.Errorf("countLines(%q) = %v, %v; expected %v", in, got, err, expected)
+		}
+	}
+}
+
+const testContentResult = `├───project
+│	├───file.txt (19b, 1 lines, text/plain; charset=utf-8)
+│	└───gopher.png (70372b, image/png)
+├───static
+│	├───a_lorem
+│	│	├───dolor.txt (empty)
+│	│	├───gopher.png (70372b, image/png)
+│	│	└───ipsum
+│	│		└───gopher.png (70372b, image/png)
+│	├───css
+│	│	└───body.css (28b, 1 lines, text/plain; charset=utf-8)
+│	├───empty.txt (empty)
+│	├───html
+│	│	└───index.html (57b, 4 lines, text/html; charset=utf-8)
+│	├───js
+│	│	└───site.js (10b, 1 lines, text/plain; charset=utf-8)
+│	└───z_lorem
+│		├───dolor.txt (empty)
+│		├───gopher.png (70372b, image/png)
+│		└───ipsum
+│			└───gopher.png (70372b, image/png)
+├───zline
+│	├───empty.txt (empty)
+│	└───lorem
+│		├───dolor.txt (empty)
+│		├───gopher.png (70372b, image/png)
+│		└───ipsum
+│			└───gopher.png (70372b, image/png)
+└───zzfile.txt (empty)
+`
+
+func TestTreeLinesMime(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := dirTreeOpts(out, "testdata", options{printFiles: true, lines: true, mime: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	if out.String() != testContentResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), testContentResult)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+)
+
+// сколько байт с начала файла хешируем, чтобы отсеять разные файлы одного размера
+const partialHashSize = 4096
+
+type dupeSummary struct {
+	groups int
+	files  int
+	wasted int64
+}
+
+func (s dupeSummary) String() string {
+	if s.groups == 0 {
+		return "no duplicates"
+	}
+	return fmt.Sprintf("duplicates: %v groups, %v files, %vb wasted", s.groups, s.files, s.wasted)
+}
+
+type fileEntry struct {
+	path string
+	size int64
+}
+
+// walkFiles обходит все файлы с теми же фильтрами, что и вывод дерева, в том же порядке
+func (w *walker) walkFiles(path string, visit func(f fileEntry)) error {
+	dir, err := w.readDir(path, true)
+	if err != nil {
+		return err
+	}
+	for _, d := range dir {
+		finfo, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entryPath := path + "/" + d.Name()
+		if d.IsDir() {
+			if w.sameFS(finfo) {
+				w.walkFiles(entryPath, visit)
+				if err := w.ctx.Err(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if finfo.Mode().IsRegular() {
+			visit(fileEntry{path: entryPath, size: finfo.Size()})
+		}
+	}
+	return nil
+}
+
+func hashFile(path string, limit int64) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if limit > 0 {
+		r = io.LimitReader(file, limit)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// groupBy разбивает группы-кандидаты по ключу, группы из одного файла отбрасываются.
+// Файл, для которого ключ не посчитался (например, он не читается), в группы не попадает -
+// как у --lines и --mime, подписи для него просто нет.
+func groupBy(groups [][]fileEntry, key func(f fileEntry) (string, error)) [][]fileEntry {
+	var res [][]fileEntry
+	for _, group := range groups {
+		byKey := map[string][]fileEntry{}
+		var keys []string
+		for _, f := range group {
+			k, err := key(f)
+			if err != nil {
+				continue
+			}
+			if _, ok := byKey[k]; !ok {
+				keys = append(keys, k)
+			}
+			byKey[k] = append(byKey[k], f)
+		}
+		for _, k := range keys {
+			if len(byKey[k]) > 1 {
+				res = append(res, byKey[k])
+			}
+		}
+	}
+	return res
+}
+
+// findDupes сравнивает файлы сначала по размеру, потом по хешу начала файла, потом по полному хешу.
+// Группы нумеруются в порядке вывода дерева.
+func (w *walker) findDupes(path string) (map[string]int, dupeSummary, error) {
+	var files []fileEntry
+	err := w.walkFiles(path, func(f fileEntry) {
+		// пустые файлы одинаковы по определению, дубликатами их не считаем
+		if f.size > 0 {
+			files = append(files, f)
+		}
+	})
+	if err != nil {
+		return nil, dupeSummary{}, err
+	}
+
+	groups := groupBy([][]fileEntry{files}, func(f fileEntry) (string, error) {
+		return fmt.Sprint(f.size), nil
+	})
+	groups = groupBy(groups, func(f fileEntry) (string, error) {
+		return hashFile(f.path, partialHashSize)
+	})
+	groups = groupBy(groups, func(f fileEntry) (string, error) {
+		if f.size <= partialHashSize {
+			return "", nil
+		}
+		return hashFile(f.path, 0)
+	})
+
+	groupOf := map[string]int{}
+	for i, group := range groups {
+		for _, f := range group {
+			groupOf[f.path] = i
+		}
+	}
+	dupes := map[string]int{}
+	numbers := map[int]int{}
+	var summary dupeSummary
+	for _, f := range files {
+		i, ok := groupOf[f.path]
+		if !ok {
+			continue
+		}
+		if _, ok := numbers[i]; !ok {
+			summary.groups++
+			numbers[i] = summary.groups
+			summary.wasted += f.size * int64(len(groups[i])-1)
+		}
+		dupes[f.path] = numbers[i]
+		summary.files++
+	}
+	return dupes, summary, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTreeDupes(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := dirTreeOpts(out, "testdata", options{printFiles: true, dupes: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	result := out.String()
+	if strings.Count(result, "gopher.png (70372b) [dup#1]\n") != 7 {
+		t.Errorf("all gophers must be in group 1\nGot:\n%v", result)
+	}
+	if strings.Contains(result, "(empty) [dup") {
+		t.Errorf("empty files must not be reported as dupes\nGot:\n%v", result)
+	}
+	expected := "\nduplicates: 1 groups, 7 files, 422232b wasted\n"
+	if !strings.HasSuffix(result, expected) {
+		t.Errorf("bad summary\nGot:\n%v\nExpected suffix:\n%v", result, expected)
+	}
+}
+
+func TestTreeDupesSameSize(t *testing.T) {
+	root := t.TempDir()
+	big := bytes.Repeat([]byte("x"), partialHashSize+10)
+	other := append([]byte{}, big...)
+	other[len(other)-1] = 'y'
+	os.WriteFile(filepath.Join(root, "a.bin"), big, 0644)
+	os.WriteFile(filepath.Join(root, "b.bin"), other, 0644)
+	os.WriteFile(filepath.Join(root, "c.bin"), big, 0644)
+	os.WriteFile(filepath.Join(root, "d.txt"), []byte("abc"), 0644)
+	os.WriteFile(filepath.Join(root, "e.txt"), []byte("abd"), 0644)
+
+	out := new(bytes.Buffer)
+	err := dirTreeOpts(out, root, options{printFiles: true, dupes: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected := `├───a.bin (4106b) [dup#1]
+├───b.bin (4106b)
+├───c.bin (4106b) [dup#1]
+├───d.txt (3b)
+└───e.txt (3b)
+
+duplicates: 1 groups, 2 files, 4106b wasted
+`
+	if out.String() != expected {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
+
+func TestDupesUnreadable(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		os.WriteFile(filepath.Join(root, name), []byte("abc"), 0644)
+	}
+	files := []fileEntry{
+		{path: filepath.Join(root, "a.txt"), size: 3},
+		{path: filepath.Join(root, "missing.txt"), size: 3},
+		{path: filepath.Join(root, "b.txt"), size: 3},
+		{path: filepath.Join(root, "c.txt"), size: 3},
+	}
+	// файл, который пропал или не читается, выпадает из групп, остальные сравниваются как обычно
+	groups := groupBy([][]fileEntry{files}, func(f fileEntry) (string, error) {
+		return hashFile(f.path, partialHashSize)
+	})
+	if len(groups) != 1 || len(groups[0]) != 3 {
+		t.Fatalf("bad groups: %v", groups)
+	}
+	for _, f := range groups[0] {
+		if strings.HasSuffix(f.path, "missing.txt") {
+			t.Errorf("unreadable file must be skipped: %v", groups)
+		}
+	}
+}
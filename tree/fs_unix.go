@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func deviceID(finfo fs.FileInfo) (uint64, bool) {
+	st, ok := finfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
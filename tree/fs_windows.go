@@ -0,0 +1,8 @@
+package main
+
+import "io/fs"
+
+// на windows устройство не определяем, -x ничего не ограничивает
+func deviceID(finfo fs.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"sort"
+	"strings"
+	"time"
 )
 
 type ByAlphabet []fs.DirEntry
@@ -14,6 +17,42 @@ func (a ByAlphabet) Len() int           { return len(a) }
 func (a ByAlphabet) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
 func (a ByAlphabet) Less(i, j int) bool { return a[i].Name() < a[j].Name() }
 
+type options struct {
+	printFiles bool
+	// showHidden выводит файлы, начинающиеся с точки (-a)
+	showHidden bool
+	// oneFS не спускается в каталоги с другого устройства (-x)
+	oneFS bool
+	// watch перерисовывает дерево при изменениях на диске (--watch)
+	watch bool
+	// dupes помечает файлы с одинаковым содержимым (--dupes)
+	dupes bool
+	// lines и mime добавляют к размеру число строк и тип содержимого (--lines, --mime)
+	lines bool
+	mime  bool
+	// bars рисует полоску с долей записи в размере родительского каталога (--bars)
+	bars bool
+	// timeout ограничивает время обхода (--timeout)
+	timeout time.Duration
+	// progress выводит в stderr, сколько уже просмотрено
+	progress bool
+}
+
+type walker struct {
+	ctx  context.Context
+	out  io.Writer
+	opts options
+	dev  uint64
+	// snap собирает состояние записей для --watch, prev - состояние с прошлой отрисовки
+	snap map[string]entryState
+	prev map[string]entryState
+	// dupes - номер группы дубликатов для каждого файла-дубликата
+	dupes map[string]int
+	// sizes - суммарный размер каждого каталога для --bars
+	sizes map[string]int64
+	stats *scanStats
+}
+
 func filterFiles(dir []fs.DirEntry) []fs.DirEntry {
 	var dirs []fs.DirEntry
 	for _, d := range dir {
@@ -24,107 +63,259 @@ func filterFiles(dir []fs.DirEntry) []fs.DirEntry {
 	return dirs
 }
 
-func deepDirTree(prefix string, isParentLast bool, out io.Writer, path string, printFiles bool) error {
+func filterHidden(dir []fs.DirEntry) []fs.DirEntry {
+	var visible []fs.DirEntry
+	for _, d := range dir {
+		if !strings.HasPrefix(d.Name(), ".") {
+			visible = append(visible, d)
+		}
+	}
+	return visible
+}
+
+func (w *walker) readDir(path string, printFiles bool) ([]fs.DirEntry, error) {
+	dir, err := w.listDir(path)
+	if err != nil {
+		return nil, err
+	}
+	return w.filterDir(dir, printFiles), nil
+}
+
+// listDir читает каталог целиком, без фильтров
+func (w *walker) listDir(path string) ([]fs.DirEntry, error) {
+	// отмену проверяем между каталогами, внутри одного ReadDir прервать нечего
+	if err := w.ctx.Err(); err != nil {
+		return nil, err
+	}
 	file, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer file.Close()
 	dir, err := file.ReadDir(0)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	w.stats.add(len(dir))
+	return dir, nil
+}
+
+func (w *walker) filterDir(dir []fs.DirEntry, printFiles bool) []fs.DirEntry {
 	if !printFiles {
 		dir = filterFiles(dir)
 	}
+	if !w.opts.showHidden {
+		dir = filterHidden(dir)
+	}
 	sort.Sort(ByAlphabet(dir))
-	countFiles := len(dir)
-	if isParentLast {
-		prefix += "	"
-	} else {
-		prefix += "│	"
+	return dir
+}
+
+// sameFS проверяет, что каталог лежит на том же устройстве, что и корень обхода
+func (w *walker) sameFS(finfo fs.FileInfo) bool {
+	if !w.opts.oneFS {
+		return true
 	}
+	dev, ok := deviceID(finfo)
+	return !ok || dev == w.dev
+}
+
+func (w *walker) fileSuffix(path string, finfo fs.FileInfo) string {
+	size := finfo.Size()
+	parts := []string{"empty"}
+	if size != 0 {
+		parts[0] = fmt.Sprintf("%vb", size)
+		if w.opts.lines || w.opts.mime {
+			parts = append(parts, describeContent(path, w.opts.lines, w.opts.mime)...)
+		}
+	}
+	suffix := " (" + strings.Join(parts, ", ") + ")"
+	if group, ok := w.dupes[path]; ok {
+		suffix += fmt.Sprintf(" [dup#%v]", group)
+	}
+	return suffix
+}
+
+func (w *walker) deepDirTree(prefix string, path string) error {
+	dir, err := w.listDir(path)
+	if err != nil {
+		return err
+	}
+	dir = w.filterDir(dir, w.opts.printFiles)
+	countFiles := len(dir)
 	for i, d := range dir {
 		finfo, err := d.Info()
 		if err != nil {
 			return err
 		}
-		size := finfo.Size()
+		entryPath := path + "/" + d.Name()
 		bytesCount := ""
 		if !d.IsDir() {
-			if size == 0 {
-				bytesCount = " (empty)"
-			} else {
-				bytesCount = fmt.Sprintf(" (%vb)", size)
-			}
+			bytesCount = w.fileSuffix(entryPath, finfo)
 		}
+		if w.opts.bars {
+			bytesCount += w.sizeBar(path, entryPath, finfo)
+		}
+		label := w.highlight(entryPath, finfo, d.Name()+bytesCount)
+		isLast := i == countFiles-1
 		outLine := ""
-		if i == countFiles-1 {
-			outLine = prefix + "└───" + d.Name() + bytesCount + "\n"
+		if isLast {
+			outLine = prefix + "└───" + label + "\n"
 		} else {
-			outLine = prefix + "├───" + d.Name() + bytesCount + "\n"
+			outLine = prefix + "├───" + label + "\n"
 		}
-		out.Write([]byte(outLine))
-		if d.IsDir() {
-			isParentLast := i == countFiles-1
-			deepDirTree(prefix, isParentLast, out, path+"/"+d.Name(), printFiles)
+		w.out.Write([]byte(outLine))
+		if d.IsDir() && w.sameFS(finfo) {
+			childPrefix := prefix + "│	"
+			if isLast {
+				childPrefix = prefix + "	"
+			}
+			w.deepDirTree(childPrefix, entryPath)
+			if err := w.ctx.Err(); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
-func dirTree(out io.Writer, path string, printFiles bool) error {
-	file, err := os.Open(path)
+func newWalker(ctx context.Context, out io.Writer, path string, opts options) (*walker, error) {
+	w := &walker{ctx: ctx, out: out, opts: opts, stats: &scanStats{}}
+	if opts.oneFS {
+		finfo, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		w.dev, _ = deviceID(finfo)
+	}
+	return w, nil
+}
+
+// render выводит дерево целиком вместе с отчётами, которые требуют отдельного прохода
+func (w *walker) render(path string) error {
+	var summary dupeSummary
+	if w.opts.bars {
+		w.stats.start("sizes")
+		w.sizes = map[string]int64{}
+		w.sumSizes(path)
+		if err := w.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	if w.opts.dupes {
+		var err error
+		w.stats.start("dupes")
+		w.dupes, summary, err = w.findDupes(path)
+		if err != nil {
+			return err
+		}
+	}
+	w.stats.start("tree")
+	err := w.deepDirTree("", path)
 	if err != nil {
 		return err
 	}
-	dir, err := file.ReadDir(0)
+	if w.opts.dupes {
+		fmt.Fprintf(w.out, "\n%v\n", summary)
+	}
+	return nil
+}
+
+func dirTreeContext(ctx context.Context, out io.Writer, path string, opts options) error {
+	w, err := newWalker(ctx, out, path, opts)
 	if err != nil {
 		return err
 	}
-	if !printFiles {
-		dir = filterFiles(dir)
+	if opts.progress {
+		stop := w.stats.report(os.Stderr, progressInterval)
+		defer stop()
 	}
-	sort.Sort(ByAlphabet(dir))
-	countFiles := len(dir)
-	for i, d := range dir {
-		finfo, err := d.Info()
-		if err != nil {
-			return err
-		}
-		size := finfo.Size()
-		bytesCount := ""
-		if !d.IsDir() {
-			if size == 0 {
-				bytesCount = " (empty)"
-			} else {
-				bytesCount = fmt.Sprintf(" (%vb)", size)
+	return w.render(path)
+}
+
+func dirTreeOpts(out io.Writer, path string, opts options) error {
+	return dirTreeContext(context.Background(), out, path, opts)
+}
+
+// DirTreeContext - то же, что dirTree, но обход прерывается при отмене ctx
+func DirTreeContext(ctx context.Context, out io.Writer, path string, printFiles bool) error {
+	return dirTreeContext(ctx, out, path, options{printFiles: printFiles, showHidden: true})
+}
+
+// dirTree сохраняет старое поведение: скрытые файлы выводятся как обычные
+func dirTree(out io.Writer, path string, printFiles bool) error {
+	return dirTreeOpts(out, path, options{printFiles: printFiles, showHidden: true})
+}
+
+func parseArgs(args []string) (string, options, error) {
+	var opts options
+	path := ""
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "-f":
+			opts.printFiles = true
+		case arg == "-a":
+			opts.showHidden = true
+		case arg == "-x":
+			opts.oneFS = true
+		case arg == "--watch":
+			opts.watch = true
+		case arg == "--dupes":
+			opts.dupes = true
+		case arg == "--lines":
+			opts.lines = true
+		case arg == "--mime":
+			opts.mime = true
+		case arg == "--bars":
+			opts.bars = true
+		case arg == "--timeout" || strings.HasPrefix(arg, "--timeout="):
+			value := strings.TrimPrefix(arg, "--timeout=")
+			if arg == "--timeout" {
+				if i+1 == len(args) {
+					return "", opts, fmt.Errorf("--timeout needs a value")
+				}
+				i++
+				value = args[i]
 			}
-		}
-		outLine := ""
-		if i == countFiles-1 {
-			outLine = "└───" + d.Name() + bytesCount + "\n"
-		} else {
-			outLine = "├───" + d.Name() + bytesCount + "\n"
-		}
-		out.Write([]byte(outLine))
-		if d.IsDir() {
-			isParentLast := i == countFiles-1
-			deepDirTree("", isParentLast, out, path+"/"+d.Name(), printFiles)
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				return "", opts, fmt.Errorf("bad timeout %s", value)
+			}
+			opts.timeout = timeout
+		default:
+			if strings.HasPrefix(arg, "-") || path != "" {
+				return "", opts, fmt.Errorf("unknown argument %s", arg)
+			}
+			path = arg
 		}
 	}
-
-	return nil
+	if path == "" {
+		return "", opts, fmt.Errorf("path is required")
+	}
+	return path, opts, nil
 }
 
 func main() {
 	out := os.Stdout
-	if !(len(os.Args) == 2 || len(os.Args) == 3) {
-		panic("usage go run main.go . [-f]")
+	path, opts, err := parseArgs(os.Args[1:])
+	if err != nil {
+		panic("usage go run main.go . [-f] [-a] [-x] [--watch] [--dupes] [--lines] [--mime] [--bars] [--timeout 10s]")
+	}
+	// в терминале прогресс не нужен - там и так видно, как идёт вывод
+	opts.progress = !isTerminal(out)
+	ctx := context.Background()
+	if opts.timeout > 0 && !opts.watch {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	if opts.watch {
+		err = watchTree(ctx, out, path, opts)
+	} else {
+		err = dirTreeContext(ctx, out, path, opts)
 	}
-	path := os.Args[1]
-	printFiles := len(os.Args) == 3 && os.Args[2] == "-f"
-	err := dirTree(out, path, printFiles)
 	if err != nil {
 		panic(err.Error())
 	}
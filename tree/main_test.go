@@ -2,7 +2,11 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 )
 
 const testFullResult = `├───project
@@ -73,3 +77,115 @@ func TestTreeDir(t *testing.T) {
 		t.Errorf("test for OK Failed - results not match\nGot:\n%v\nExpected:\n%v", result, testDirResult)
 	}
 }
+
+func TestTreeHidden(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, ".git"), 0755)
+	os.WriteFile(filepath.Join(root, ".env"), []byte("x"), 0644)
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main"), 0644)
+
+	out := new(bytes.Buffer)
+	err := dirTreeOpts(out, root, options{printFiles: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected := "└───main.go (12b)\n"
+	if out.String() != expected {
+		t.Errorf("hidden files must be skipped\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+
+	out.Reset()
+	err = dirTreeOpts(out, root, options{printFiles: true, showHidden: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	expected = "├───.env (1b)\n├───.git\n└───main.go (12b)\n"
+	if out.String() != expected {
+		t.Errorf("-a must show hidden files\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
+
+func TestTreeOneFS(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := dirTreeOpts(out, "testdata", options{printFiles: true, showHidden: true, oneFS: true})
+	if err != nil {
+		t.Errorf("test for OK Failed - error")
+	}
+	if out.String() != testFullResult {
+		t.Errorf("-x must not change output on single device\nGot:\n%v\nExpected:\n%v", out.String(), testFullResult)
+	}
+}
+
+func TestParseArgs(t *testing.T) {
+	path, opts, err := parseArgs([]string{".", "-f", "-a", "-x"})
+	if err != nil || path != "." || !opts.printFiles || !opts.showHidden || !opts.oneFS {
+		t.Errorf("bad parse result: %v %+v %v", path, opts, err)
+	}
+	if _, _, err := parseArgs([]string{"-f"}); err == nil {
+		t.Errorf("expected error for missing path")
+	}
+	if _, _, err := parseArgs([]string{".", "--nope"}); err == nil {
+		t.Errorf("expected error for unknown flag")
+	}
+}
+
+func TestDirTreeContext(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := DirTreeContext(context.Background(), out, "testdata", true)
+	if err != nil || out.String() != testFullResult {
+		t.Errorf("results not match\nGot:\n%v\nExpected:\n%v", out.String(), testFullResult)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	out.Reset()
+	err = DirTreeContext(ctx, out, "testdata", true)
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("nothing must be printed after cancel\nGot:\n%v", out.String())
+	}
+}
+
+// cancelWriter отменяет контекст после заданного числа записей вывода
+type cancelWriter struct {
+	bytes.Buffer
+	lines  int
+	cancel context.CancelFunc
+}
+
+func (w *cancelWriter) Write(p []byte) (int, error) {
+	w.lines--
+	if w.lines == 0 {
+		w.cancel()
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestDirTreeContextCancelMidway(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	out := &cancelWriter{lines: 3, cancel: cancel}
+	err := DirTreeContext(ctx, out, "testdata", true)
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	expected := "├───project\n│	├───file.txt (19b)\n│	└───gopher.png (70372b)\n"
+	if out.String() != expected {
+		t.Errorf("walk must stop on next directory\nGot:\n%v\nExpected:\n%v", out.String(), expected)
+	}
+}
+
+func TestParseArgsTimeout(t *testing.T) {
+	for _, args := range [][]string{{".", "--timeout", "5s"}, {"--timeout=5s", "."}} {
+		_, opts, err := parseArgs(args)
+		if err != nil || opts.timeout != 5*time.Second {
+			t.Errorf("bad parse result for %v: %+v %v", args, opts, err)
+		}
+	}
+	for _, args := range [][]string{{".", "--timeout"}, {".", "--timeout=abc"}, {".", "--timeout=-1s"}} {
+		if _, _, err := parseArgs(args); err == nil {
+			t.Errorf("expected error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const progressInterval = time.Second
+
+// scanStats считает прочитанные каталоги и записи в текущем проходе по дереву.
+// --bars и --dupes обходят дерево заранее, у каждого прохода свои счётчики и подпись
+type scanStats struct {
+	mu      sync.Mutex
+	phase   string
+	dirs    int64
+	entries int64
+}
+
+// start начинает новый проход с нуля
+func (s *scanStats) start(phase string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.phase, s.dirs, s.entries = phase, 0, 0
+}
+
+func (s *scanStats) add(entries int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirs++
+	s.entries += int64(entries)
+}
+
+func (s *scanStats) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%v: scanned %v dirs, %v entries", s.phase, s.dirs, s.entries)
+}
+
+// report раз в interval пишет в out, сколько уже просмотрено.
+// Возвращает функцию, которая останавливает вывод и пишет итоговую строку.
+func (s *scanStats) report(out io.Writer, interval time.Duration) func() {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				fmt.Fprintln(out, s)
+				return
+			case <-ticker.C:
+				fmt.Fprintln(out, s)
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	finfo, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return finfo.Mode()&os.ModeCharDevice != 0
+}
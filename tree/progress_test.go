@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestScanStats(t *testing.T) {
+	expected := "tree: scanned 13 dirs, 29 entries"
+	var w *walker
+	// у отдельных проходов --bars и --dupes свои счётчики, вывод считается с нуля
+	for _, opts := range []options{{printFiles: true}, {printFiles: true, bars: true, dupes: true}} {
+		var err error
+		w, err = newWalker(context.Background(), new(bytes.Buffer), "testdata", opts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := w.render("testdata"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if w.stats.String() != expected {
+			t.Errorf("bad stats with %+v\nGot: %v\nExpected: %v", opts, w.stats, expected)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	stop := w.stats.report(out, progressInterval)
+	stop()
+	if out.String() != expected+"\n" {
+		t.Errorf("final line must be printed on stop\nGot: %q", out.String())
+	}
+}
+
+func TestScanStatsPhases(t *testing.T) {
+	w, err := newWalker(context.Background(), new(bytes.Buffer), "testdata", options{printFiles: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	w.stats.start("sizes")
+	w.sizes = map[string]int64{}
+	w.sumSizes("testdata")
+	if expected := "sizes: scanned 13 dirs, 29 entries"; w.stats.String() != expected {
+		t.Errorf("bad stats of --bars pass\nGot: %v\nExpected: %v", w.stats, expected)
+	}
+	w.stats.start("dupes")
+	if _, _, err := w.findDupes("testdata"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "dupes: scanned 13 dirs, 29 entries"; w.stats.String() != expected {
+		t.Errorf("bad stats of --dupes pass\nGot: %v\nExpected: %v", w.stats, expected)
+	}
+}
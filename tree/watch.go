@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+const (
+	clearScreen    = "\033[H\033[2J"
+	highlightStart = "\033[1;33m"
+	highlightEnd   = "\033[0m"
+
+	pollInterval  = time.Second
+	watchDebounce = 100 * time.Millisecond
+)
+
+type entryState struct {
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+// notifier сообщает, что под корнем что-то могло измениться
+type notifier interface {
+	// watch получает список всех каталогов после очередной отрисовки
+	watch(dirs []string)
+	// wait блокируется до следующего изменения, false - если пора остановиться
+	wait(stop <-chan struct{}) bool
+	close()
+}
+
+type pollNotifier struct {
+	interval time.Duration
+}
+
+func newPollNotifier() *pollNotifier {
+	return &pollNotifier{interval: pollInterval}
+}
+
+func (n *pollNotifier) watch(dirs []string) {}
+
+// при опросе дерево просто перечитывается раз в interval, а сравнение mtime делает watchTree
+func (n *pollNotifier) wait(stop <-chan struct{}) bool {
+	select {
+	case <-stop:
+		return false
+	case <-time.After(n.interval):
+		return true
+	}
+}
+
+func (n *pollNotifier) close() {}
+
+// highlight запоминает состояние записи и подсвечивает её, если она появилась или изменилась
+func (w *walker) highlight(path string, finfo fs.FileInfo, label string) string {
+	if w.snap == nil {
+		return label
+	}
+	state := entryState{size: finfo.Size(), modTime: finfo.ModTime(), isDir: finfo.IsDir()}
+	w.snap[path] = state
+	if w.prev == nil {
+		return label
+	}
+	if prevState, ok := w.prev[path]; ok && !changed(prevState, state) {
+		return label
+	}
+	return highlightStart + label + highlightEnd
+}
+
+func changed(a, b entryState) bool {
+	if a.isDir != b.isDir {
+		return true
+	}
+	// у каталога mtime меняется при любом добавлении/удалении, но сами изменения видны на детях
+	if a.isDir {
+		return false
+	}
+	return a.size != b.size || !a.modTime.Equal(b.modTime)
+}
+
+func sameSnapshot(a, b map[string]entryState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		other, ok := b[path]
+		if !ok || changed(state, other) || (state.isDir && !state.modTime.Equal(other.modTime)) {
+			return false
+		}
+	}
+	return true
+}
+
+func removedPaths(prev, cur map[string]entryState) []string {
+	var removed []string
+	for path := range prev {
+		if _, ok := cur[path]; !ok {
+			removed = append(removed, path)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// renderSnapshot рисует дерево в буфер, подсвечивая отличия от prev
+func renderSnapshot(ctx context.Context, path string, opts options, prev map[string]entryState) (*bytes.Buffer, map[string]entryState, error) {
+	// в --watch таймаут ограничивает каждую отрисовку, а не всё наблюдение
+	if opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.timeout)
+		defer cancel()
+	}
+	buf := new(bytes.Buffer)
+	w, err := newWalker(ctx, buf, path, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	w.snap = map[string]entryState{}
+	w.prev = prev
+	err = w.render(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, removed := range removedPaths(prev, w.snap) {
+		buf.WriteString(highlightStart + "removed: " + removed + highlightEnd + "\n")
+	}
+	return buf, w.snap, nil
+}
+
+// watchTree перерисовывает дерево при каждом изменении под path, пока не отменят ctx
+func watchTree(ctx context.Context, out io.Writer, path string, opts options) error {
+	n := newNotifier()
+	defer n.close()
+	var prev map[string]entryState
+	for {
+		buf, snap, err := renderSnapshot(ctx, path, opts, prev)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if prev == nil || !sameSnapshot(prev, snap) {
+			io.WriteString(out, clearScreen)
+			out.Write(buf.Bytes())
+			prev = snap
+		}
+		dirs := []string{path}
+		for p, state := range snap {
+			if state.isDir {
+				dirs = append(dirs, p)
+			}
+		}
+		n.watch(dirs)
+		if !n.wait(ctx.Done()) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY | syscall.IN_ATTRIB |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF | syscall.IN_MOVE_SELF
+
+type inotifyNotifier struct {
+	fd      int
+	file    *os.File
+	watches map[string]int
+	events  chan struct{}
+	// если inotify отвалился посреди работы - дальше опрашиваем по mtime
+	fallback *pollNotifier
+}
+
+func newNotifier() notifier {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return newPollNotifier()
+	}
+	n := &inotifyNotifier{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		watches: map[string]int{},
+		events:  make(chan struct{}, 1),
+	}
+	go n.readLoop()
+	return n
+}
+
+func (n *inotifyNotifier) readLoop() {
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		_, err := n.file.Read(buf)
+		if err != nil {
+			close(n.events)
+			return
+		}
+		select {
+		case n.events <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *inotifyNotifier) watch(dirs []string) {
+	current := map[string]bool{}
+	for _, dir := range dirs {
+		current[dir] = true
+		if _, ok := n.watches[dir]; ok {
+			continue
+		}
+		wd, err := syscall.InotifyAddWatch(n.fd, dir, inotifyMask)
+		if err != nil {
+			continue
+		}
+		n.watches[dir] = wd
+	}
+	for dir, wd := range n.watches {
+		if !current[dir] {
+			syscall.InotifyRmWatch(n.fd, uint32(wd))
+			delete(n.watches, dir)
+		}
+	}
+}
+
+func (n *inotifyNotifier) wait(stop <-chan struct{}) bool {
+	if n.fallback != nil {
+		return n.fallback.wait(stop)
+	}
+	select {
+	case <-stop:
+		return false
+	case _, ok := <-n.events:
+		if !ok {
+			n.fallback = newPollNotifier()
+			return true
+		}
+	}
+	// пачку событий от одной записи склеиваем в одну перерисовку
+	select {
+	case <-stop:
+		return false
+	case <-time.After(watchDebounce):
+	}
+	select {
+	case <-n.events:
+	default:
+	}
+	return true
+}
+
+func (n *inotifyNotifier) close() {
+	n.file.Close()
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+func newNotifier() notifier {
+	return newPollNotifier()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWatchHighlight(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(root, "b.txt"), []byte("b"), 0644)
+	opts := options{printFiles: true}
+
+	_, prev, err := renderSnapshot(context.Background(), root, opts, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	os.WriteFile(filepath.Join(root, "b.txt"), []byte("bbb"), 0644)
+	os.WriteFile(filepath.Join(root, "c.txt"), []byte("c"), 0644)
+	os.Remove(filepath.Join(root, "a.txt"))
+
+	buf, _, err := renderSnapshot(context.Background(), root, opts, prev)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "├───" + highlightStart + "b.txt (3b)" + highlightEnd + "\n" +
+		"└───" + highlightStart + "c.txt (1b)" + highlightEnd + "\n" +
+		highlightStart + "removed: " + root + "/a.txt" + highlightEnd + "\n"
+	if buf.String() != expected {
+		t.Errorf("changes not highlighted\nGot:\n%q\nExpected:\n%q", buf.String(), expected)
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchRedraw(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "build"), 0755)
+	out := &syncBuffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- watchTree(ctx, out, root, options{printFiles: true})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	os.WriteFile(filepath.Join(root, "build", "app.bin"), []byte("bin"), 0644)
+
+	expected := highlightStart + "app.bin (3b)" + highlightEnd
+	deadline := time.Now().Add(3 * time.Second)
+	for !strings.Contains(out.String(), expected) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("new file not redrawn\nGot:\n%q", out.String())
+	}
+	if strings.Count(out.String(), clearScreen) < 2 {
+		t.Errorf("expected at least two renders\nGot:\n%q", out.String())
+	}
+}
+
+func TestWatchTimeout(t *testing.T) {
+	// отрисовка, не уложившаяся в --timeout, - ошибка, а не тихий конец наблюдения
+	err := watchTree(context.Background(), new(bytes.Buffer), "testdata", options{printFiles: true, timeout: time.Nanosecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+}